@@ -14,16 +14,21 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
 
 	ocinfrav1 "github.com/openshift/api/config/v1"
+	imageregistryv1alpha1 "github.com/stolostron/cluster-lifecycle-api/imageregistry/v1alpha1"
 	"github.com/stolostron/klusterlet-addon-controller/pkg/apis"
 	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
 	"github.com/stolostron/klusterlet-addon-controller/pkg/controller"
+	"github.com/stolostron/klusterlet-addon-controller/pkg/helpers"
 	"github.com/stolostron/klusterlet-addon-controller/version"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	managedclusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
 	manifestworkv1 "open-cluster-management.io/api/work/v1"
 
 	// "github.com/operator-framework/operator-sdk/pkg/k8sutil"
@@ -32,6 +37,7 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
@@ -58,8 +64,16 @@ func printVersion() {
 
 func main() {
 	var metricsAddr string
+	var imageManifestDir string
+	var hubVersion string
 
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&imageManifestDir, "image-manifest-dir", "",
+		"The directory of the JSON or YAML image manifest files keyed by version. The image-manifest configmaps "+
+			"take precedence over the files with the same version.")
+	flag.StringVar(&hubVersion, "hub-version", "",
+		"The hub version used to select the image manifest. It takes precedence over the env HUB_VERSION and "+
+			"the version of the MultiClusterHub, and it is not synced from the MultiClusterHub.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New())
@@ -77,12 +91,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	err = agentv1.LoadConfigmaps(runtimeClient)
-	if err != nil {
-		log.Error(err, "")
-		os.Exit(1)
-	}
-
 	kubeClient, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
 		log.Error(err, "")
@@ -94,22 +102,56 @@ func main() {
 		log.Error(err, "")
 		os.Exit(1)
 	}
-	version.Version = os.Getenv("HUB_VERSION")
+	// the MultiClusterHub and the image manifests are not used in the standalone mode
+	standalone := strings.EqualFold(os.Getenv(agentv1.StandaloneModeEnv), "true")
+	agentv1.SetStandaloneMode(standalone)
+
+	// the hub version of the flag takes precedence over the env, which takes precedence over the MultiClusterHub
+	version.Version = hubVersion
 	if version.Version == "" {
+		version.Version = os.Getenv(agentv1.HubVersionEnv)
+	}
+	if version.Version != "" || standalone {
+		agentv1.SetStaticHubVersion(version.Version)
+	} else {
 		version.Version, err = agentv1.GetHubVersion(context.Background(), dynamicClient)
 		if err != nil {
 			log.Error(err, "failed to get hub version.")
 			os.Exit(1)
 		}
+		agentv1.SetHubVersion(version.Version)
 	}
 	printVersion()
 
+	// the image manifest best matching the hub version is used
+	if standalone {
+		log.Info("Running in the standalone mode, the image manifests are not loaded.")
+	} else {
+		if imageManifestDir != "" {
+			if err := agentv1.LoadManifestFiles(imageManifestDir); err != nil {
+				log.Error(err, "")
+				os.Exit(1)
+			}
+		}
+		if err := agentv1.LoadConfigmaps(runtimeClient); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	}
+
 	// Create a new Cmd to provide shared dependencies and start components
 	mgr, err := manager.New(cfg, manager.Options{
 		Namespace:          os.Getenv("WATCH_NAMESPACE"),
 		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
 		LeaderElection:     true,
 		LeaderElectionID:   "klusterlet-addon-controller-lock",
+		NewClient:          newCachedClient,
+		// the manifestWorks are not watched, they are read by name without caching all of the manifestWorks on the hub
+		ClientDisableCacheFor: []client.Object{&manifestworkv1.ManifestWork{}},
+		// only the data of the image pull secrets and the proxy credentials secrets are cached for the secrets
+		NewCache: cache.BuilderWithOptions(cache.Options{
+			TransformByObject: cache.TransformByObject{&corev1.Secret{}: helpers.TransformSecret},
+		}),
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -144,6 +186,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := imageregistryv1alpha1.AddToScheme(mgr.GetScheme()); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+
+	if err := clusterv1beta1.AddToScheme(mgr.GetScheme()); err != nil {
+		log.Error(err, "")
+		os.Exit(1)
+	}
+
 	if err := apis.AddToScheme(mgr.GetScheme()); err != nil {
 		log.Error(err, "")
 		os.Exit(1)
@@ -172,3 +224,20 @@ func newRuntimeClient(conf *rest.Config) (client.Client, error) {
 	}
 	return kubeClient, nil
 }
+
+// newCachedClient returns a client which reads the unstructured objects from the cache as well, like the
+// MultiClusterHubs and the mirror sets of the hub, they are watched by the controllers and listed in every reconcile.
+func newCachedClient(cache cache.Cache, config *rest.Config, options client.Options,
+	uncachedObjects ...client.Object) (client.Client, error) {
+	c, err := client.New(config, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.NewDelegatingClient(client.NewDelegatingClientInput{
+		CacheReader:       cache,
+		Client:            c,
+		UncachedObjects:   uncachedObjects,
+		CacheUnstructured: true,
+	})
+}
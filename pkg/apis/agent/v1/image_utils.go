@@ -10,16 +10,26 @@ package v1
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
 	"github.com/stolostron/cluster-lifecycle-api/helpers/imageregistry"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
 
-	"github.com/stolostron/klusterlet-addon-controller/version"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -41,28 +51,227 @@ import (
 //	}
 const ocmVersionLabel = "ocm-release-version"
 
+const (
+	// ImageManifestTypeLabel is the label key of the image-manifest configmaps
+	ImageManifestTypeLabel = "ocm-configmap-type"
+	// ImageManifestType is the label value of the image-manifest configmaps
+	ImageManifestType = "image-manifest"
+
+	// ImageManifestFallbackVersionEnv is the env name of the image manifest version which is used if there is no
+	// image manifest matching the hub version.
+	ImageManifestFallbackVersionEnv = "IMAGE_MANIFEST_FALLBACK_VERSION"
+
+	// HubVersionEnv is the env name of the hub version. The hub version is not synced from the MultiClusterHub if it
+	// is set.
+	HubVersionEnv = "HUB_VERSION"
+
+	// StandaloneModeEnv is the env name of the flag to run the controller on an open-cluster-management hub without
+	// the MultiClusterHub. The image manifests are not used in the standalone mode, so the addons are deployed with
+	// the images of their charts unless the images are overridden.
+	StandaloneModeEnv = "STANDALONE_MODE"
+
+	// ClusterClaimArchitecture is the name of the ClusterClaim of the ManagedCluster, which is the CPU architecture
+	// of the cluster nodes, like amd64, arm64, ppc64le and s390x.
+	ClusterClaimArchitecture = "architecture.open-cluster-management.io"
+)
+
+// architectureAliases maps the architecture names reported by uname to the names used in the image manifest.
+var architectureAliases = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+}
+
+// ArchitectureImageNotFoundError is returned if the image manifest has no image of the component for the
+// architecture of the ManagedCluster.
+type ArchitectureImageNotFoundError struct {
+	Component    string
+	Architecture string
+}
+
+func (e *ArchitectureImageNotFoundError) Error() string {
+	return fmt.Sprintf("addon image %s not found for architecture %s", e.Component, e.Architecture)
+}
+
+// IsArchitectureImageNotFound returns true if the error is an ArchitectureImageNotFoundError.
+func IsArchitectureImageNotFound(err error) bool {
+	var archErr *ArchitectureImageNotFoundError
+	return errors.As(err, &archErr)
+}
+
 // Manifest contains the manifest.
 // The Manifest is loaded using the LoadManifest method.
 
-var versionList []string
-
+// The images of an architecture are keyed by the image key suffixed with the architecture, like
+// search_collector.arm64, and the images keyed by the image key are used for all of the other architectures.
 type manifest struct {
 	Images map[string]string
 }
 
-var manifests map[string]manifest
+// image returns the image of the component for the architecture. The image of the component is returned if the
+// architecture is unknown. An ArchitectureImageNotFoundError is returned if the component only has the images of
+// the other architectures.
+func (m *manifest) image(component, arch string) (string, error) {
+	if arch != "" {
+		if image := m.Images[component+"."+arch]; image != "" {
+			return image, nil
+		}
+	}
+
+	if image := m.Images[component]; image != "" {
+		return image, nil
+	}
+	// the image is only built for the other architectures
+	for key := range m.Images {
+		if arch != "" && imageKey(key) == component {
+			return "", &ArchitectureImageNotFoundError{Component: component, Architecture: arch}
+		}
+	}
+	return "", fmt.Errorf("addon image not found")
+}
+
+// hasArchitectureImage returns true if the manifest has the image of the component keyed by the architecture.
+func (m *manifest) hasArchitectureImage(component, arch string) bool {
+	return arch != "" && m.Images[component+"."+arch] != ""
+}
+
+// imageKey returns the image key of a key in the image manifest, the architecture suffix is removed.
+func imageKey(key string) string {
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// manifestStore is the thread-safe store of the image manifests of each version and the hub version, the manifests
+// are swapped as a whole when the image-manifest configmaps are reloaded.
+type manifestStore struct {
+	lock       sync.RWMutex
+	hubVersion string
+	// staticHubVersion is true if the hub version is set by the flag or the env, then it is not synced from the
+	// MultiClusterHub.
+	staticHubVersion bool
+	// manifests is the image manifests of the files merged with the image manifests of the configmaps
+	manifests          map[string]manifest
+	fileManifests      map[string]manifest
+	configmapManifests map[string]manifest
+}
+
+var manifests = &manifestStore{}
+
+// standaloneMode is true if the controller runs in the standalone mode.
+var standaloneMode atomic.Bool
+
+// SetStandaloneMode enables or disables the standalone mode, in which the image manifests are not used.
+func SetStandaloneMode(enabled bool) {
+	standaloneMode.Store(enabled)
+}
+
+// IsStandaloneMode returns true if the controller runs in the standalone mode.
+func IsStandaloneMode() bool {
+	return standaloneMode.Load()
+}
+
+// get returns the manifest best matching the version, and the version of the manifest.
+func (s *manifestStore) get(version string) (manifest, string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if len(s.manifests) == 0 {
+		return manifest{}, "", fmt.Errorf("image manifest not loaded")
+	}
+	manifestVersion, ok := bestMatchVersion(s.manifests, version)
+	if !ok {
+		return manifest{}, "", fmt.Errorf("version %s not supported", version)
+	}
+	return s.manifests[manifestVersion], manifestVersion, nil
+}
+
+// getLoaded returns the manifest of the version only if it is loaded.
+func (s *manifestStore) getLoaded(version string) (manifest, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	m, ok := s.manifests[version]
+	return m, ok
+}
+
+// bestMatchVersion returns the version of the manifest best matching the version. The manifest of the same version
+// is matched first, then the manifest of the highest patch version within the same minor version, then the manifest
+// of the fallback version.
+func bestMatchVersion(manifests map[string]manifest, version string) (string, bool) {
+	if _, ok := manifests[version]; ok {
+		return version, true
+	}
+
+	if required, err := utilversion.ParseGeneric(version); err == nil {
+		var matched string
+		var matchedVersion *utilversion.Version
+		for v := range manifests {
+			candidate, err := utilversion.ParseGeneric(v)
+			if err != nil || candidate.Major() != required.Major() || candidate.Minor() != required.Minor() {
+				continue
+			}
+			// the greater version string is matched if the versions are equal, like 2.8.1 and v2.8.1
+			if matchedVersion == nil || matchedVersion.LessThan(candidate) ||
+				(!candidate.LessThan(matchedVersion) && v > matched) {
+				matched, matchedVersion = v, candidate
+			}
+		}
+		if matchedVersion != nil {
+			return matched, true
+		}
+	}
+
+	fallback := os.Getenv(ImageManifestFallbackVersionEnv)
+	if _, ok := manifests[fallback]; ok && fallback != "" {
+		return fallback, true
+	}
+	return "", false
+}
+
+// merge merges the image manifests of the files and the configmaps. The image manifest of a configmap takes
+// precedence over the image manifest of a file with the same version.
+func (s *manifestStore) merge() {
+	merged := make(map[string]manifest, len(s.fileManifests)+len(s.configmapManifests))
+	for version, m := range s.fileManifests {
+		merged[version] = m
+	}
+	for version, m := range s.configmapManifests {
+		merged[version] = m
+	}
+	s.manifests = merged
+}
+
+// update applies the change to the store, and returns the sorted image keys whose images in the manifest used
+// for the hub version are changed.
+func (s *manifestStore) update(change func(s *manifestStore)) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	previousVersion, _ := bestMatchVersion(s.manifests, s.hubVersion)
+	previous := s.manifests[previousVersion].Images
+	change(s)
+	currentVersion, ok := bestMatchVersion(s.manifests, s.hubVersion)
+	switch {
+	case !ok:
+		klog.Warningf("no image manifest matches the hub version %s", s.hubVersion)
+	case currentVersion != previousVersion:
+		klog.Infof("the image manifest %s is used for the hub version %s", currentVersion, s.hubVersion)
+	}
+	return changedImageKeys(previous, s.manifests[currentVersion].Images)
+}
 
 // GetImage returns the image.  for the specified component return error if information not found
 func (config *AddonAgentConfig) GetImage(component string) (imageRepository string, err error) {
 
-	m, err := getManifest(version.Version)
+	m, err := getManifest(HubVersion())
 	if err != nil {
 		return "", err
 	}
 
-	image := m.Images[component]
-	if image == "" {
-		return "", fmt.Errorf("addon image not found")
+	image, err := m.image(component, ManagedClusterArchitecture(config.ManagedCluster))
+	if err != nil {
+		return "", err
 	}
 
 	return imageregistry.OverrideImageByAnnotation(config.ManagedCluster.GetAnnotations(), image)
@@ -70,60 +279,222 @@ func (config *AddonAgentConfig) GetImage(component string) (imageRepository stri
 
 // GetImage returns the image.  for the specified component return error if information not found
 func GetImage(managedCluster *clusterv1.ManagedCluster, component string) (string, error) {
-	m, err := getManifest(version.Version)
+	return GetImageOfManifestVersion(managedCluster, component, "")
+}
+
+// GetImageOfManifestVersion returns the image of the component in the image manifest of the manifestVersion if it
+// is loaded, otherwise in the image manifest best matching the hub version.
+func GetImageOfManifestVersion(managedCluster *clusterv1.ManagedCluster, component, manifestVersion string) (string, error) {
+	m, err := manifestOfVersion(manifestVersion)
 	if err != nil {
 		return "", err
 	}
 
-	image := m.Images[component]
-	if image == "" {
-		return "", fmt.Errorf("addon image not found")
+	image, err := m.image(component, ManagedClusterArchitecture(managedCluster))
+	if err != nil {
+		return "", err
 	}
 
 	return imageregistry.OverrideImageByAnnotation(managedCluster.GetAnnotations(), image)
 }
 
+// IsArchitectureImage returns true if the image of the component used by GetImageOfManifestVersion is keyed by the
+// architecture of the ManagedCluster, which is different from the image of the other architectures.
+func IsArchitectureImage(managedCluster *clusterv1.ManagedCluster, component, manifestVersion string) bool {
+	m, err := manifestOfVersion(manifestVersion)
+	if err != nil {
+		return false
+	}
+	return m.hasArchitectureImage(component, ManagedClusterArchitecture(managedCluster))
+}
+
+// manifestOfVersion returns the image manifest of the manifestVersion if it is loaded, otherwise the image manifest
+// best matching the hub version.
+func manifestOfVersion(manifestVersion string) (*manifest, error) {
+	if manifestVersion != "" {
+		if m, ok := manifests.getLoaded(manifestVersion); ok {
+			return &m, nil
+		}
+	}
+	return getManifest(HubVersion())
+}
+
+// ManagedClusterArchitecture returns the architecture reported by the ClusterClaim of the ManagedCluster. An empty
+// architecture is returned if it is not reported.
+func ManagedClusterArchitecture(managedCluster *clusterv1.ManagedCluster) string {
+	if managedCluster == nil {
+		return ""
+	}
+	for _, claim := range managedCluster.Status.ClusterClaims {
+		if claim.Name != ClusterClaimArchitecture {
+			continue
+		}
+		arch := strings.ToLower(strings.TrimSpace(claim.Value))
+		if alias, ok := architectureAliases[arch]; ok {
+			return alias
+		}
+		return arch
+	}
+	return ""
+}
+
 // getManifest returns the manifest that is best matching the required version
 func getManifest(version string) (*manifest, error) {
-	if len(versionList) == 0 || manifests == nil {
-		return nil, fmt.Errorf("image manifest not loaded")
+	m, _, err := manifests.get(version)
+	if err != nil {
+		return nil, err
 	}
+	return &m, nil
+}
 
-	if m, ok := manifests[version]; ok {
-		return &m, nil
-	}
+// GetImageManifestVersion returns the version of the image manifest which is used for the hub version
+func GetImageManifestVersion() (string, error) {
+	_, manifestVersion, err := manifests.get(HubVersion())
+	return manifestVersion, err
+}
+
+// IsImageManifestLoaded returns true if the image manifest of the version is loaded.
+func IsImageManifestLoaded(version string) bool {
+	_, ok := manifests.getLoaded(version)
+	return ok
+}
 
-	return nil, fmt.Errorf("version %s not supported", version)
+// HubVersion returns the hub version which is used to select the image manifest
+func HubVersion() string {
+	manifests.lock.RLock()
+	defer manifests.lock.RUnlock()
+	return manifests.hubVersion
+}
+
+// SetHubVersion updates the hub version which is used to select the image manifest. It returns the sorted
+// image keys whose images are changed by the hub version.
+func SetHubVersion(hubVersion string) []string {
+	return manifests.update(func(s *manifestStore) {
+		s.hubVersion = hubVersion
+	})
+}
+
+// SetStaticHubVersion sets the hub version which is not synced from the MultiClusterHub, like the hub version set
+// by the flag or the env.
+func SetStaticHubVersion(hubVersion string) {
+	manifests.update(func(s *manifestStore) {
+		s.hubVersion = hubVersion
+		s.staticHubVersion = true
+	})
+}
+
+// IsStaticHubVersion returns true if the hub version is not synced from the MultiClusterHub.
+func IsStaticHubVersion() bool {
+	manifests.lock.RLock()
+	defer manifests.lock.RUnlock()
+	return manifests.staticHubVersion
 }
 
 // LoadConfigmaps - loads pre-release image manifests
 func LoadConfigmaps(k8s client.Client) error {
-	manifests = make(map[string]manifest)
-	configmapList := &corev1.ConfigMapList{}
+	_, err := ReloadConfigmaps(context.TODO(), k8s)
+	return err
+}
 
-	err := k8s.List(context.TODO(), configmapList, client.MatchingLabels{"ocm-configmap-type": "image-manifest"})
+// ReloadConfigmaps loads the image manifests from the image-manifest configmaps and swaps the loaded manifests
+// with them. It returns the sorted image keys whose images are changed by the loaded manifests.
+func ReloadConfigmaps(ctx context.Context, k8s client.Client) ([]string, error) {
+	configmapList := &corev1.ConfigMapList{}
+	err := k8s.List(ctx, configmapList, client.MatchingLabels{ImageManifestTypeLabel: ImageManifestType})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	loaded := make(map[string]manifest, len(configmapList.Items))
 	for _, cm := range configmapList.Items {
 		omcVersion := cm.Labels[ocmVersionLabel]
-		m := manifest{}
-		m.Images = make(map[string]string)
-		m.Images = cm.Data
-		manifests[omcVersion] = m
+		m := manifest{Images: make(map[string]string, len(cm.Data))}
+		for key, image := range cm.Data {
+			m.Images[key] = image
+		}
+		loaded[omcVersion] = m
+	}
+
+	return manifests.update(func(s *manifestStore) {
+		s.configmapManifests = loaded
+		s.merge()
+	}), nil
+}
+
+// LoadManifestFiles loads the image manifests from the JSON or YAML files in the directory, each file maps the
+// versions to the images of the versions, like {"2.9.0": {"search_collector": "quay.io/..."}}. The image manifests
+// of the files are merged with the image manifests of the configmaps, which take precedence over the files.
+func LoadManifestFiles(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read the image manifest directory %s. err:%v", dir, err)
+	}
 
-		versionList = append(versionList, omcVersion)
+	loaded := map[string]manifest{}
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".json", ".yaml", ".yml":
+		default:
+			continue
+		}
+		if entry.IsDir() {
+			continue
+		}
+
+		name := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("failed to read the image manifest file %s. err:%v", name, err)
+		}
+		versions := map[string]map[string]string{}
+		if err := yaml.Unmarshal(data, &versions); err != nil {
+			return fmt.Errorf("failed to unmarshal the image manifest file %s. err:%v", name, err)
+		}
+		for version, images := range versions {
+			if _, ok := loaded[version]; ok {
+				return fmt.Errorf("the image manifest of version %s is duplicated in the file %s", version, name)
+			}
+			loaded[version] = manifest{Images: images}
+		}
 	}
+
+	manifests.update(func(s *manifestStore) {
+		s.fileManifests = loaded
+		s.merge()
+	})
 	return nil
 }
 
+// changedImageKeys returns the sorted image keys which are added, removed or changed. The image key of an
+// architecture image is returned without the architecture suffix.
+func changedImageKeys(previous, current map[string]string) []string {
+	keys := map[string]bool{}
+	for key, image := range current {
+		if previous[key] != image {
+			keys[imageKey(key)] = true
+		}
+	}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			keys[imageKey(key)] = true
+		}
+	}
+
+	var sorted []string
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
 var MCHgvr = schema.GroupVersionResource{
 	Group:    "operator.open-cluster-management.io",
 	Version:  "v1",
 	Resource: "multiclusterhubs",
 }
 
+// GetHubVersion returns the current version of the MultiClusterHub
 func GetHubVersion(ctx context.Context, dynamicClient dynamic.Interface) (string, error) {
 
 	mchList, err := dynamicClient.Resource(MCHgvr).List(ctx, metav1.ListOptions{})
@@ -133,11 +504,30 @@ func GetHubVersion(ctx context.Context, dynamicClient dynamic.Interface) (string
 	if len(mchList.Items) == 0 {
 		return "", fmt.Errorf("get 0 mch instance")
 	}
+	return MultiClusterHubVersion(mchList.Items)
+}
 
-	mch := mchList.Items[0]
-	hubVersion, _, err := unstructured.NestedString(mch.Object, "status", "currentVersion")
-	if err != nil {
-		return "", fmt.Errorf("failed to version from mch. err: %v", err)
+// MultiClusterHubVersion returns the current version of the MultiClusterHubs. If there are multiple
+// MultiClusterHubs, they are sorted by namespace and name, and the first one with the current version is used.
+// An empty version is returned if there is no MultiClusterHub with the current version.
+func MultiClusterHubVersion(mchs []unstructured.Unstructured) (string, error) {
+	sorted := make([]unstructured.Unstructured, len(mchs))
+	copy(sorted, mchs)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].GetNamespace() != sorted[j].GetNamespace() {
+			return sorted[i].GetNamespace() < sorted[j].GetNamespace()
+		}
+		return sorted[i].GetName() < sorted[j].GetName()
+	})
+
+	for _, mch := range sorted {
+		hubVersion, _, err := unstructured.NestedString(mch.Object, "status", "currentVersion")
+		if err != nil {
+			return "", fmt.Errorf("failed to version from mch. err: %v", err)
+		}
+		if hubVersion != "" {
+			return hubVersion, nil
+		}
 	}
-	return hubVersion, nil
+	return "", nil
 }
@@ -10,11 +10,13 @@ package v1
 
 import (
 	"context"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
 	imageregistryv1alpha1 "github.com/stolostron/cluster-lifecycle-api/imageregistry/v1alpha1"
-	"github.com/stolostron/klusterlet-addon-controller/version"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -26,7 +28,7 @@ import (
 )
 
 func TestGetImageWithManifest(t *testing.T) {
-	version.Version = "x.y.z"
+	SetHubVersion("x.y.z")
 	testConfigMap := &corev1.ConfigMap{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: corev1.SchemeGroupVersion.String(),
@@ -65,7 +67,7 @@ func TestGetImageWithManifest(t *testing.T) {
 		wantErr bool
 	}{
 		{
-			name: "Use Component Sha in " + version.Version,
+			name: "Use Component Sha in " + HubVersion(),
 			args: args{
 				addonAgentConfig: &AddonAgentConfig{
 					ManagedCluster: &clusterv1.ManagedCluster{
@@ -177,7 +179,7 @@ func TestGetImageWithManyConfigmapManifest(t *testing.T) {
 		},
 	}
 
-	version.Version = "x.y.z"
+	SetHubVersion("x.y.z")
 	client := fake.NewFakeClient([]runtime.Object{
 		testConfigMap, testConfigMap1, testConfigMapInvalidVersion,
 	}...)
@@ -197,7 +199,7 @@ func TestGetImageWithManyConfigmapManifest(t *testing.T) {
 		wantErr bool
 	}{
 		{
-			name: "Use Component Sha in " + version.Version,
+			name: "Use Component Sha in " + HubVersion(),
 			args: args{
 				addonAgentConfig: &AddonAgentConfig{
 					ManagedCluster: &clusterv1.ManagedCluster{
@@ -251,6 +253,211 @@ func TestGetImageWithManyConfigmapManifest(t *testing.T) {
 	}
 }
 
+func TestReloadConfigmaps(t *testing.T) {
+	SetHubVersion("x.y.z")
+	newImageManifest := func(name, version string, images map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test-namespace",
+				Labels: map[string]string{
+					ImageManifestTypeLabel: ImageManifestType,
+					ocmVersionLabel:        version,
+				},
+			},
+			Data: images,
+		}
+	}
+
+	client := fake.NewClientBuilder().WithObjects(
+		newImageManifest("test-configmap-x.y.z", "x.y.z", map[string]string{
+			"cert_policy_controller": "sample-registry/cert-policy-controller@sha256:1",
+			"iam_policy_controller":  "sample-registry/iam-policy-controller@sha256:1",
+		}),
+		newImageManifest("test-configmap-2.2.1", "2.2.1", map[string]string{
+			"cert_policy_controller": "sample-registry/cert-policy-controller@sha256:0",
+		}),
+	).Build()
+	if err := LoadConfigmaps(client); err != nil {
+		t.Fatalf("failed to load configmaps. err: %v", err)
+	}
+
+	// the configmap of the hub version is updated, and the configmap of another version is deleted
+	if err := client.Update(context.TODO(), newImageManifest("test-configmap-x.y.z", "x.y.z", map[string]string{
+		"cert_policy_controller":            "sample-registry/cert-policy-controller@sha256:2",
+		"governance_policy_framework_addon": "sample-registry/governance-policy-framework-addon@sha256:2",
+	})); err != nil {
+		t.Fatalf("failed to update configmap. err: %v", err)
+	}
+	if err := client.Delete(context.TODO(), newImageManifest("test-configmap-2.2.1", "2.2.1", nil)); err != nil {
+		t.Fatalf("failed to delete configmap. err: %v", err)
+	}
+
+	changedImageKeys, err := ReloadConfigmaps(context.TODO(), client)
+	if err != nil {
+		t.Fatalf("failed to reload configmaps. err: %v", err)
+	}
+	assert.Equal(t, []string{"cert_policy_controller", "governance_policy_framework_addon", "iam_policy_controller"},
+		changedImageKeys)
+
+	m, err := getManifest("x.y.z")
+	if err != nil {
+		t.Fatalf("failed to get manifest. err: %v", err)
+	}
+	assert.Equal(t, "sample-registry/cert-policy-controller@sha256:2", m.Images["cert_policy_controller"])
+	if _, err := getManifest("2.2.1"); err == nil {
+		t.Errorf("expected the manifest of the deleted configmap is removed")
+	}
+
+	changedImageKeys, err = ReloadConfigmaps(context.TODO(), client)
+	if err != nil {
+		t.Fatalf("failed to reload configmaps. err: %v", err)
+	}
+	if len(changedImageKeys) != 0 {
+		t.Errorf("expected no changed images, but got %v", changedImageKeys)
+	}
+}
+
+func TestLoadManifestFiles(t *testing.T) {
+	SetHubVersion("x.y.z")
+	t.Cleanup(func() {
+		manifests.update(func(s *manifestStore) {
+			s.fileManifests = nil
+			s.merge()
+		})
+	})
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"x.y.z.yaml": `
+x.y.z:
+  cert_policy_controller: file-registry/cert-policy-controller@sha256:1
+  iam_policy_controller: file-registry/iam-policy-controller@sha256:1
+`,
+		"2.2.1.json": `{"2.2.1": {"cert_policy_controller": "file-registry/cert-policy-controller@sha256:0"}}`,
+		"README.md":  "not an image manifest",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write file %s. err: %v", name, err)
+		}
+	}
+	if err := LoadManifestFiles(dir); err != nil {
+		t.Fatalf("failed to load manifest files. err: %v", err)
+	}
+	if err := LoadConfigmaps(fake.NewClientBuilder().Build()); err != nil {
+		t.Fatalf("failed to load configmaps. err: %v", err)
+	}
+
+	m, err := getManifest("x.y.z")
+	if err != nil {
+		t.Fatalf("failed to get manifest. err: %v", err)
+	}
+	assert.Equal(t, "file-registry/cert-policy-controller@sha256:1", m.Images["cert_policy_controller"])
+	m, err = getManifest("2.2.1")
+	if err != nil {
+		t.Fatalf("failed to get manifest. err: %v", err)
+	}
+	assert.Equal(t, "file-registry/cert-policy-controller@sha256:0", m.Images["cert_policy_controller"])
+
+	// the image manifest of the configmap takes precedence over the file with the same version
+	client := fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-configmap-x.y.z",
+			Namespace: "test-namespace",
+			Labels: map[string]string{
+				ImageManifestTypeLabel: ImageManifestType,
+				ocmVersionLabel:        "x.y.z",
+			},
+		},
+		Data: map[string]string{"cert_policy_controller": "sample-registry/cert-policy-controller@sha256:2"},
+	}).Build()
+	changedImageKeys, err := ReloadConfigmaps(context.TODO(), client)
+	if err != nil {
+		t.Fatalf("failed to reload configmaps. err: %v", err)
+	}
+	assert.Equal(t, []string{"cert_policy_controller", "iam_policy_controller"}, changedImageKeys)
+	m, err = getManifest("x.y.z")
+	if err != nil {
+		t.Fatalf("failed to get manifest. err: %v", err)
+	}
+	assert.Equal(t, map[string]string{"cert_policy_controller": "sample-registry/cert-policy-controller@sha256:2"},
+		m.Images)
+
+	// the image manifest of a version is not allowed in multiple files
+	if err := os.WriteFile(filepath.Join(dir, "x.y.z.yml"), []byte("x.y.z: {}"), 0600); err != nil {
+		t.Fatalf("failed to write file. err: %v", err)
+	}
+	if err := LoadManifestFiles(dir); err == nil {
+		t.Errorf("expected error of the duplicated version")
+	}
+}
+
+func Test_bestMatchVersion(t *testing.T) {
+	loaded := map[string]manifest{
+		"2.7.3":  {},
+		"2.8.0":  {},
+		"2.8.2":  {},
+		"2.8.10": {},
+		"2.9.0":  {},
+	}
+
+	tests := []struct {
+		name            string
+		version         string
+		fallback        string
+		expectedVersion string
+		expectedOK      bool
+	}{
+		{
+			name:            "exact match",
+			version:         "2.8.2",
+			expectedVersion: "2.8.2",
+			expectedOK:      true,
+		},
+		{
+			name:            "highest patch within the same minor",
+			version:         "2.8.5",
+			expectedVersion: "2.8.10",
+			expectedOK:      true,
+		},
+		{
+			name:            "z-stream version with suffix",
+			version:         "2.7.4-SNAPSHOT",
+			expectedVersion: "2.7.3",
+			expectedOK:      true,
+		},
+		{
+			name:            "fallback version",
+			version:         "2.10.0",
+			fallback:        "2.9.0",
+			expectedVersion: "2.9.0",
+			expectedOK:      true,
+		},
+		{
+			name:       "fallback version is not loaded",
+			version:    "2.10.0",
+			fallback:   "2.6.0",
+			expectedOK: false,
+		},
+		{
+			name:       "invalid version",
+			version:    "x.y.z",
+			expectedOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ImageManifestFallbackVersionEnv, tt.fallback)
+			manifestVersion, ok := bestMatchVersion(loaded, tt.version)
+			if ok != tt.expectedOK || manifestVersion != tt.expectedVersion {
+				t.Errorf("expected version %q %v, but got %q %v", tt.expectedVersion, tt.expectedOK, manifestVersion, ok)
+			}
+		})
+	}
+}
+
 var fakeMCHJson = `{
     "apiVersion": "operator.open-cluster-management.io/v1",
     "kind": "MultiClusterHub",
@@ -289,3 +496,147 @@ func Test_GetHubVersion(t *testing.T) {
 		t.Errorf("expected version x.y.z,but got %v", hubVersion)
 	}
 }
+
+func Test_MultiClusterHubVersion(t *testing.T) {
+	newMCH := func(namespace, name, currentVersion string) unstructured.Unstructured {
+		mch := unstructured.Unstructured{}
+		mch.SetNamespace(namespace)
+		mch.SetName(name)
+		if currentVersion != "" {
+			_ = unstructured.SetNestedField(mch.Object, currentVersion, "status", "currentVersion")
+		}
+		return mch
+	}
+
+	tests := []struct {
+		name            string
+		mchs            []unstructured.Unstructured
+		expectedVersion string
+	}{
+		{
+			name:            "no mch",
+			expectedVersion: "",
+		},
+		{
+			name: "multiple mchs",
+			mchs: []unstructured.Unstructured{
+				newMCH("open-cluster-management", "multiclusterhub", "2.9.0"),
+				newMCH("acm", "multiclusterhub", "2.8.0"),
+			},
+			expectedVersion: "2.8.0",
+		},
+		{
+			name: "mch is installing",
+			mchs: []unstructured.Unstructured{
+				newMCH("acm", "multiclusterhub", ""),
+				newMCH("open-cluster-management", "multiclusterhub", "2.9.0"),
+			},
+			expectedVersion: "2.9.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hubVersion, err := MultiClusterHubVersion(tt.mchs)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if hubVersion != tt.expectedVersion {
+				t.Errorf("expected version %q, but got %q", tt.expectedVersion, hubVersion)
+			}
+		})
+	}
+}
+
+func Test_manifestImage(t *testing.T) {
+	m := &manifest{Images: map[string]string{
+		"search_collector":               "quay.io/stolostron/search-collector:multiarch",
+		"search_collector.s390x":         "quay.io/stolostron/search-collector:s390x",
+		"config_policy_controller.amd64": "quay.io/stolostron/config-policy-controller:amd64",
+	}}
+
+	tests := []struct {
+		name              string
+		component         string
+		arch              string
+		expectedImage     string
+		expectedErr       bool
+		expectedArch      bool
+		expectedArchImage bool
+	}{
+		{
+			name:          "architecture is unknown",
+			component:     "search_collector",
+			expectedImage: "quay.io/stolostron/search-collector:multiarch",
+		},
+		{
+			name:              "image of the architecture",
+			component:         "search_collector",
+			arch:              "s390x",
+			expectedImage:     "quay.io/stolostron/search-collector:s390x",
+			expectedArchImage: true,
+		},
+		{
+			name:          "image of all architectures",
+			component:     "search_collector",
+			arch:          "arm64",
+			expectedImage: "quay.io/stolostron/search-collector:multiarch",
+		},
+		{
+			name:         "no image for the architecture",
+			component:    "config_policy_controller",
+			arch:         "arm64",
+			expectedErr:  true,
+			expectedArch: true,
+		},
+		{
+			name:        "image not found",
+			component:   "iam_policy_controller",
+			arch:        "arm64",
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			image, err := m.image(tt.component, tt.arch)
+			if (err != nil) != tt.expectedErr {
+				t.Errorf("expected error %v, but got %v", tt.expectedErr, err)
+			}
+			if IsArchitectureImageNotFound(err) != tt.expectedArch {
+				t.Errorf("expected architecture image not found %v, but got %v", tt.expectedArch, err)
+			}
+			assert.Equal(t, tt.expectedImage, image)
+			assert.Equal(t, tt.expectedArchImage, m.hasArchitectureImage(tt.component, tt.arch))
+		})
+	}
+}
+
+func Test_ManagedClusterArchitecture(t *testing.T) {
+	newCluster := func(claims ...clusterv1.ManagedClusterClaim) *clusterv1.ManagedCluster {
+		return &clusterv1.ManagedCluster{Status: clusterv1.ManagedClusterStatus{ClusterClaims: claims}}
+	}
+
+	assert.Equal(t, "", ManagedClusterArchitecture(nil))
+	assert.Equal(t, "", ManagedClusterArchitecture(newCluster(
+		clusterv1.ManagedClusterClaim{Name: "platform.open-cluster-management.io", Value: "AWS"})))
+	assert.Equal(t, "s390x", ManagedClusterArchitecture(newCluster(
+		clusterv1.ManagedClusterClaim{Name: ClusterClaimArchitecture, Value: "s390x"})))
+	assert.Equal(t, "amd64", ManagedClusterArchitecture(newCluster(
+		clusterv1.ManagedClusterClaim{Name: ClusterClaimArchitecture, Value: "X86_64"})))
+}
+
+func Test_changedImageKeys(t *testing.T) {
+	previous := map[string]string{
+		"search_collector":       "quay.io/stolostron/search-collector:1",
+		"search_collector.arm64": "quay.io/stolostron/search-collector:arm64-1",
+		"cert_policy_controller": "quay.io/stolostron/cert-policy-controller:1",
+	}
+	current := map[string]string{
+		"search_collector":             "quay.io/stolostron/search-collector:1",
+		"search_collector.arm64":       "quay.io/stolostron/search-collector:arm64-2",
+		"cert_policy_controller":       "quay.io/stolostron/cert-policy-controller:1",
+		"cert_policy_controller.s390x": "quay.io/stolostron/cert-policy-controller:s390x-1",
+	}
+	assert.Equal(t, []string{"cert_policy_controller", "search_collector"}, changedImageKeys(previous, current))
+}
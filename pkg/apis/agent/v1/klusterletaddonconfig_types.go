@@ -39,6 +39,22 @@ type KlusterletAddonConfigSpec struct {
 	// +optional
 	ProxyConfig ProxyConfig `json:"proxyConfig,omitempty"`
 
+	// ProxyProfile is the name of the cluster-scoped ProxyProfile. If it is set, the proxy configuration of the
+	// ProxyProfile is used instead of the ProxyConfig by the addon agents with the CustomProxy or Auto policy.
+	// +optional
+	ProxyProfile string `json:"proxyProfile,omitempty"`
+
+	// ImageOverrides is the map of the image key in the image manifest, like search_collector, to the image which
+	// overrides the image of all addon agents using the image key. The images are not rewritten by the
+	// ClusterImageRegistries of the ManagedCluster.
+	// +optional
+	ImageOverrides map[string]string `json:"imageOverrides,omitempty"`
+
+	// ManifestVersion pins the addon agents to the image manifest of the version, like 2.8.0, instead of the image
+	// manifest best matching the hub version. The pin is ignored if the image manifest of the version is not loaded.
+	// +optional
+	ManifestVersion string `json:"manifestVersion,omitempty"`
+
 	// SearchCollectorConfig defines the configurations of SearchCollector addon agent.
 	SearchCollectorConfig KlusterletAddonAgentConfigSpec `json:"searchCollector"`
 
@@ -53,8 +69,30 @@ type KlusterletAddonConfigSpec struct {
 
 	// IAMPolicyControllerConfig defines the configurations of IamPolicyController addon agent.
 	IAMPolicyControllerConfig KlusterletAddonAgentConfigSpec `json:"iamPolicyController"`
+
+	// WorkManagerConfig defines the configurations of WorkManager addon agent. WorkManager is always enabled, and its
+	// values are managed by the controller only if ManageValues is true.
+	// +optional
+	WorkManagerConfig *WorkManagerConfigSpec `json:"workManager,omitempty"`
+
+	// NodePlacementPolicy defines the policy to set the node placement of the addon agents. default is Default.
+	// Default means that the addon agent pods use the nodeSelector synced from the MultiClusterHub for local-cluster.
+	// KlusterletNodePlacement means that the addon agent pods use the nodePlacement of the KlusterletConfig
+	// which applies to the ManagedCluster. It is the KlusterletConfig named by the
+	// agent.open-cluster-management.io/klusterlet-config annotation of the ManagedCluster, or the KlusterletConfig
+	// named global if the ManagedCluster has no such annotation.
+	// +kubebuilder:validation:Enum=Default;KlusterletNodePlacement
+	// +optional
+	NodePlacementPolicy NodePlacementPolicy `json:"nodePlacementPolicy,omitempty"`
 }
 
+type NodePlacementPolicy string
+
+const (
+	NodePlacementPolicyDefault    NodePlacementPolicy = "Default"
+	NodePlacementPolicyKlusterlet NodePlacementPolicy = "KlusterletNodePlacement"
+)
+
 // ProxyConfig defines the global proxy env for OCP cluster
 type ProxyConfig struct {
 	// HTTPProxy is the URL of the proxy for HTTP requests.  Empty means unset and will not result in an env var.
@@ -67,11 +105,37 @@ type ProxyConfig struct {
 
 	// NoProxy is a comma-separated list of hostnames and/or CIDRs for which the proxy should not be used.
 	// Empty means unset and will not result in an env var.
-	// The API Server of Hub cluster should be added here.
+	// The hosts of the API Server of Hub cluster are added automatically unless the KlusterletAddonConfig has the
+	// annotation klusterletaddonconfig-disable-hub-noproxy=true.
 	// And If you scale up workers that are not included in the network defined by the networking.machineNetwork[].cidr
 	// field from the installation configuration, you must add them to this list to prevent connection issues.
 	// +optional
 	NoProxy string `json:"noProxy,omitempty"`
+
+	// TrustedCABundle references a ConfigMap containing the PEM-encoded CA bundle which is used by the addon agents
+	// to trust the proxy, it is required by the TLS-intercepting proxies. The ConfigMap is in the namespace of the
+	// KlusterletAddonConfig.
+	// +optional
+	TrustedCABundle *ConfigMapReference `json:"trustedCABundle,omitempty"`
+
+	// CredentialsSecret references a Secret containing the username and password of the proxy.
+	// The credentials are added to the HTTPProxy and HTTPSProxy, so the credentials should not be in the URLs.
+	// The Secret is in the namespace of the KlusterletAddonConfig, and it must have the username and password keys.
+	// The credentials are deployed by a ManifestWork in the namespace of the cluster, or the hosting cluster for the
+	// hosted addons, so they can be read by anyone who can read the ManifestWorks in that namespace.
+	// +optional
+	CredentialsSecret *SecretReference `json:"credentialsSecret,omitempty"`
+}
+
+// ConfigMapReference references a key of a ConfigMap in the namespace of the KlusterletAddonConfig
+type ConfigMapReference struct {
+	// Name is the name of the ConfigMap.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key is the key of the CA bundle in the ConfigMap. default is ca-bundle.crt.
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
 type ProxyPolicy string
@@ -80,6 +144,8 @@ const (
 	ProxyPolicyDisable        ProxyPolicy = "Disabled"
 	ProxyPolicyOCPGlobalProxy ProxyPolicy = "OCPGlobalProxy"
 	ProxyPolicyCustomProxy    ProxyPolicy = "CustomProxy"
+	ProxyPolicyAuto           ProxyPolicy = "Auto"
+	ProxyPolicyKlusterlet     ProxyPolicy = "KlusterletProxy"
 )
 
 // KlusterletAddonAgentConfigSpec defines configuration for each addon agent.
@@ -92,9 +158,58 @@ type KlusterletAddonAgentConfigSpec struct {
 	// Disabled means that the addon agent pods do not configure the proxy env variables.
 	// OCPGlobalProxy means that the addon agent pods use the cluster-wide proxy config of OCP cluster provisioned by ACM.
 	// CustomProxy means that the addon agent pods use the ProxyConfig specified in KlusterletAddonConfig.
-	// +kubebuilder:validation:Enum=Disabled;OCPGlobalProxy;CustomProxy
+	// Auto means that CustomProxy is used if the ProxyConfig is specified in KlusterletAddonConfig, OCPGlobalProxy is
+	// used if the cluster-wide proxy config is detected, otherwise Disabled.
+	// KlusterletProxy means that the addon agent pods use the hub proxy config of the KlusterletConfig which applies
+	// to the ManagedCluster.
+	// The in-cluster destinations, like .svc, .cluster.local, localhost and the service and pod networks, are added
+	// into the noProxy. It is resolved to Disabled if the KlusterletConfig has no hub proxy config.
+	// +kubebuilder:validation:Enum=Disabled;OCPGlobalProxy;CustomProxy;Auto;KlusterletProxy
 	// +optional
 	ProxyPolicy ProxyPolicy `json:"proxyPolicy,omitempty"`
+
+	// AdditionalNoProxy is a list of hostnames and/or CIDRs which are appended to the noProxy of this addon agent
+	// only, whichever ProxyPolicy is in effect. It is ignored if the addon agent does not use a proxy.
+	// +optional
+	AdditionalNoProxy []string `json:"additionalNoProxy,omitempty"`
+
+	// ImageOverrides is the map of the image key in the image manifest to the image which overrides the image of
+	// this addon agent only. It takes precedence over the ImageOverrides of the KlusterletAddonConfig.
+	// +optional
+	ImageOverrides map[string]string `json:"imageOverrides,omitempty"`
+
+	// ManifestVersion pins this addon agent to the image manifest of the version. It takes precedence over the
+	// ManifestVersion of the KlusterletAddonConfig.
+	// +optional
+	ManifestVersion string `json:"manifestVersion,omitempty"`
+}
+
+// WorkManagerConfigSpec defines the configurations of WorkManager addon agent.
+type WorkManagerConfigSpec struct {
+	// ManageValues is the flag to manage the values of WorkManager by the controller. default is false.
+	// The values set by the controller, like the node placement, the proxy and the image overrides, are removed from
+	// WorkManager if it is false.
+	// +optional
+	ManageValues bool `json:"manageValues,omitempty"`
+
+	// ProxyPolicy defines the policy to set proxy for WorkManager, the same as the ProxyPolicy of the other addon
+	// agents. default is Disabled.
+	// +kubebuilder:validation:Enum=Disabled;OCPGlobalProxy;CustomProxy;Auto;KlusterletProxy
+	// +optional
+	ProxyPolicy ProxyPolicy `json:"proxyPolicy,omitempty"`
+
+	// AdditionalNoProxy is a list of hostnames and/or CIDRs which are appended to the noProxy of WorkManager only.
+	// +optional
+	AdditionalNoProxy []string `json:"additionalNoProxy,omitempty"`
+
+	// ImageOverrides is the map of the image key in the image manifest to the image which overrides the image of
+	// WorkManager only.
+	// +optional
+	ImageOverrides map[string]string `json:"imageOverrides,omitempty"`
+
+	// ManifestVersion pins WorkManager to the image manifest of the version.
+	// +optional
+	ManifestVersion string `json:"manifestVersion,omitempty"`
 }
 
 const (
@@ -104,6 +219,68 @@ const (
 	ReasonOCPGlobalProxyDetectedFail string = "OCPGlobalProxyNotDetectedFail"
 )
 
+const (
+	// ManifestVersionPinned is the condition of the image manifest versions pinned by the KlusterletAddonConfig and
+	// its addon agents. It is False if a pinned version is not loaded, then the image manifest best matching the hub
+	// version is used instead.
+	ManifestVersionPinned                string = "ManifestVersionPinned"
+	ReasonPinnedManifestVersionLoaded    string = "PinnedManifestVersionLoaded"
+	ReasonPinnedManifestVersionNotLoaded string = "PinnedManifestVersionNotLoaded"
+)
+
+const (
+	// ImageResolved is the condition of an addon agent. It is False if the images of the addon agent are not
+	// resolved, then the addon agent is not updated.
+	ImageResolved           string = "ImageResolved"
+	ReasonImagesResolved    string = "ImagesResolved"
+	ReasonImagesNotResolved string = "ImagesNotResolved"
+)
+
+const (
+	// ArchitectureImagesAvailable is the condition of the images of the enabled addon agents for the architecture
+	// reported by the ClusterClaim of the ManagedCluster. It is False if the image manifest has no image of an addon
+	// agent for the architecture, then the addon agent is not updated.
+	ArchitectureImagesAvailable      string = "ArchitectureImagesAvailable"
+	ReasonArchitectureImagesFound    string = "ArchitectureImagesFound"
+	ReasonArchitectureImagesNotFound string = "ArchitectureImagesNotFound"
+)
+
+const (
+	// ImagesAllowed is the condition of the images of the enabled addon agents against the image policy of the hub.
+	// It is False if an image override or a rewritten image is not allowed by the policy, then the addon agent is
+	// not updated.
+	ImagesAllowed                  string = "ImagesAllowed"
+	ReasonImagesAllowedByPolicy    string = "ImagesAllowedByPolicy"
+	ReasonImagesNotAllowedByPolicy string = "ImagesNotAllowedByPolicy"
+)
+
+const (
+	// ProxyProfileResolved is the condition of the ProxyProfile referenced by the KlusterletAddonConfig. It is False
+	// if the ProxyProfile is not found, then the ProxyConfig of the KlusterletAddonConfig is used instead.
+	ProxyProfileResolved       string = "ProxyProfileResolved"
+	ReasonProxyProfileFound    string = "ProxyProfileFound"
+	ReasonProxyProfileNotFound string = "ProxyProfileNotFound"
+)
+
+const (
+	// ImageRegistryApplied is the condition of the ManagedClusterImageRegistry applied to the images of the addon
+	// agents. It is False if the ManagedCluster has the ClusterImageRegistries annotation but no
+	// ManagedClusterImageRegistry selects it, then the images are rewritten by the annotation which may be stale.
+	ImageRegistryApplied                      string = "ImageRegistryApplied"
+	ReasonManagedClusterImageRegistrySelected string = "ManagedClusterImageRegistrySelected"
+	ReasonImageRegistryAnnotationNotSelected  string = "ImageRegistryAnnotationNotSelected"
+)
+
+// SecretReference references a Secret in the namespace of the KlusterletAddonConfig
+type SecretReference struct {
+	// Name is the name of the Secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// DefaultTrustedCABundleKey is the default key of the CA bundle in the ConfigMap referenced by TrustedCABundle
+const DefaultTrustedCABundleKey = "ca-bundle.crt"
+
 // KlusterletAddonConfigStatus defines the observed state of KlusterletAddonConfig
 type KlusterletAddonConfigStatus struct {
 	// OCPGlobalProxy is the cluster-wide proxy config of the OCP cluster provisioned by ACM
@@ -113,6 +290,87 @@ type KlusterletAddonConfigStatus struct {
 	// Conditions contains condition information for the klusterletAddonConfig
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Addons contains the observed state of each enabled addon agent
+	// +optional
+	Addons []KlusterletAddonStatus `json:"addons,omitempty"`
+
+	// InstallConfig contains the platform facts in the install config of the OCP cluster provisioned by ACM
+	// +optional
+	InstallConfig *InstallConfigStatus `json:"installConfig,omitempty"`
+
+	// ImageManifestVersion is the version of the image manifest which is used to resolve the images of the addon
+	// agents. It is the image manifest best matching the hub version.
+	// +optional
+	ImageManifestVersion string `json:"imageManifestVersion,omitempty"`
+
+	// ImageRegistry is the ManagedClusterImageRegistry which is applied to the images of the addon agents. It is
+	// the ManagedClusterImageRegistry whose placement selects the ManagedCluster.
+	// +optional
+	ImageRegistry *ImageRegistryStatus `json:"imageRegistry,omitempty"`
+}
+
+// ImageRegistryStatus references the ManagedClusterImageRegistry applied to the images of the addon agents
+type ImageRegistryStatus struct {
+	// Name is the name of the ManagedClusterImageRegistry.
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the ManagedClusterImageRegistry.
+	Namespace string `json:"namespace"`
+
+	// PullSecret is the image pull secret of the ManagedClusterImageRegistry in the format namespace.name.
+	// +optional
+	PullSecret string `json:"pullSecret,omitempty"`
+}
+
+// InstallConfigStatus defines the platform facts in the install config of the OCP cluster
+type InstallConfigStatus struct {
+	// Platform is the platform type of the cluster, like aws, baremetal and none.
+	// +optional
+	Platform string `json:"platform,omitempty"`
+
+	// Region is the region of the cluster, empty means the platform has no region.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// BaseDomain is the base domain of the cluster.
+	// +optional
+	BaseDomain string `json:"baseDomain,omitempty"`
+
+	// NetworkType is the type of the cluster network, like OVNKubernetes and OpenShiftSDN.
+	// +optional
+	NetworkType string `json:"networkType,omitempty"`
+
+	// ClusterNetwork is the CIDRs of the pod network.
+	// +optional
+	ClusterNetwork []string `json:"clusterNetwork,omitempty"`
+
+	// MachineNetwork is the CIDRs of the machine network.
+	// +optional
+	MachineNetwork []string `json:"machineNetwork,omitempty"`
+
+	// ServiceNetwork is the CIDRs of the service network.
+	// +optional
+	ServiceNetwork []string `json:"serviceNetwork,omitempty"`
+}
+
+// KlusterletAddonStatus defines the observed state of an addon agent
+type KlusterletAddonStatus struct {
+	// Name is the name of the addon.
+	Name string `json:"name"`
+
+	// ProxyPolicy is the proxy policy applied to the addon agent. The Auto policy is resolved to
+	// Disabled, OCPGlobalProxy or CustomProxy.
+	// +optional
+	ProxyPolicy ProxyPolicy `json:"proxyPolicy,omitempty"`
+
+	// Images is the map of the image key in the image manifest to the effective image of the addon agent.
+	// +optional
+	Images map[string]string `json:"images,omitempty"`
+
+	// Conditions contains condition information for the addon agent, like ImageResolved.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -0,0 +1,77 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProxyProfileSpec defines the proxy configuration shared by the KlusterletAddonConfigs which reference the profile
+type ProxyProfileSpec struct {
+	// HTTPProxy is the URL of the proxy for HTTP requests.  Empty means unset and will not result in an env var.
+	// +optional
+	HTTPProxy string `json:"httpProxy,omitempty"`
+
+	// HTTPSProxy is the URL of the proxy for HTTPS requests.  Empty means unset and will not result in an env var.
+	// +optional
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+
+	// NoProxy is a comma-separated list of hostnames and/or CIDRs for which the proxy should not be used.
+	// Empty means unset and will not result in an env var.
+	// +optional
+	NoProxy string `json:"noProxy,omitempty"`
+
+	// TrustedCABundle references a ConfigMap containing the PEM-encoded CA bundle which is used by the addon agents
+	// to trust the proxy. The ConfigMap is in the namespace of each KlusterletAddonConfig referencing the profile
+	// if the namespace is not set.
+	// +optional
+	TrustedCABundle *NamespacedConfigMapReference `json:"trustedCABundle,omitempty"`
+
+	// CredentialsSecret references a Secret containing the username and password of the proxy. The Secret is in the
+	// namespace of each KlusterletAddonConfig referencing the profile if the namespace is not set. The
+	// CredentialsSecret of the KlusterletAddonConfig is used if it is not set.
+	// +optional
+	CredentialsSecret *NamespacedSecretReference `json:"credentialsSecret,omitempty"`
+}
+
+// NamespacedConfigMapReference references a key of a ConfigMap in a namespace
+type NamespacedConfigMapReference struct {
+	ConfigMapReference `json:",inline"`
+
+	// Namespace is the namespace of the ConfigMap.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// NamespacedSecretReference references a Secret in a namespace
+type NamespacedSecretReference struct {
+	SecretReference `json:",inline"`
+
+	// Namespace is the namespace of the Secret.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ProxyProfile is the Schema for the proxyprofiles API
+// +kubebuilder:resource:path=proxyprofiles,scope=Cluster
+type ProxyProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ProxyProfileSpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ProxyProfileList contains a list of proxyProfile
+type ProxyProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProxyProfile `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ProxyProfile{}, &ProxyProfileList{})
+}
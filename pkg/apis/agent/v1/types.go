@@ -79,4 +79,5 @@ var KlusterletAddonImageNames = map[string][]string{
 	PolicyAddonName:          []string{"config_policy_controller", "governance_policy_framework_addon"},
 	PolicyFrameworkAddonName: []string{"governance_policy_framework_addon"},
 	SearchAddonName:          []string{"search_collector"},
+	WorkManagerAddonName:     []string{"multicloud_manager"},
 }
@@ -42,6 +42,21 @@ func (in *AddonAgentConfig) DeepCopy() *AddonAgentConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapReference) DeepCopyInto(out *ConfigMapReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapReference.
+func (in *ConfigMapReference) DeepCopy() *ConfigMapReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GlobalValues) DeepCopyInto(out *GlobalValues) {
 	*out = *in
@@ -78,9 +93,66 @@ func (in *GlobalValues) DeepCopy() *GlobalValues {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageRegistryStatus) DeepCopyInto(out *ImageRegistryStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageRegistryStatus.
+func (in *ImageRegistryStatus) DeepCopy() *ImageRegistryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageRegistryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstallConfigStatus) DeepCopyInto(out *InstallConfigStatus) {
+	*out = *in
+	if in.ClusterNetwork != nil {
+		in, out := &in.ClusterNetwork, &out.ClusterNetwork
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MachineNetwork != nil {
+		in, out := &in.MachineNetwork, &out.MachineNetwork
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ServiceNetwork != nil {
+		in, out := &in.ServiceNetwork, &out.ServiceNetwork
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstallConfigStatus.
+func (in *InstallConfigStatus) DeepCopy() *InstallConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(InstallConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KlusterletAddonAgentConfigSpec) DeepCopyInto(out *KlusterletAddonAgentConfigSpec) {
 	*out = *in
+	if in.AdditionalNoProxy != nil {
+		in, out := &in.AdditionalNoProxy, &out.AdditionalNoProxy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ImageOverrides != nil {
+		in, out := &in.ImageOverrides, &out.ImageOverrides
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KlusterletAddonAgentConfigSpec.
@@ -162,12 +234,24 @@ func (in *KlusterletAddonConfigSpec) DeepCopyInto(out *KlusterletAddonConfigSpec
 			(*out)[key] = val
 		}
 	}
-	out.ProxyConfig = in.ProxyConfig
-	out.SearchCollectorConfig = in.SearchCollectorConfig
-	out.PolicyController = in.PolicyController
-	out.ApplicationManagerConfig = in.ApplicationManagerConfig
-	out.CertPolicyControllerConfig = in.CertPolicyControllerConfig
-	out.IAMPolicyControllerConfig = in.IAMPolicyControllerConfig
+	in.ProxyConfig.DeepCopyInto(&out.ProxyConfig)
+	if in.ImageOverrides != nil {
+		in, out := &in.ImageOverrides, &out.ImageOverrides
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.SearchCollectorConfig.DeepCopyInto(&out.SearchCollectorConfig)
+	in.PolicyController.DeepCopyInto(&out.PolicyController)
+	in.ApplicationManagerConfig.DeepCopyInto(&out.ApplicationManagerConfig)
+	in.CertPolicyControllerConfig.DeepCopyInto(&out.CertPolicyControllerConfig)
+	in.IAMPolicyControllerConfig.DeepCopyInto(&out.IAMPolicyControllerConfig)
+	if in.WorkManagerConfig != nil {
+		in, out := &in.WorkManagerConfig, &out.WorkManagerConfig
+		*out = new(WorkManagerConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KlusterletAddonConfigSpec.
@@ -183,7 +267,7 @@ func (in *KlusterletAddonConfigSpec) DeepCopy() *KlusterletAddonConfigSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KlusterletAddonConfigStatus) DeepCopyInto(out *KlusterletAddonConfigStatus) {
 	*out = *in
-	out.OCPGlobalProxy = in.OCPGlobalProxy
+	in.OCPGlobalProxy.DeepCopyInto(&out.OCPGlobalProxy)
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]metav1.Condition, len(*in))
@@ -191,6 +275,23 @@ func (in *KlusterletAddonConfigStatus) DeepCopyInto(out *KlusterletAddonConfigSt
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Addons != nil {
+		in, out := &in.Addons, &out.Addons
+		*out = make([]KlusterletAddonStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.InstallConfig != nil {
+		in, out := &in.InstallConfig, &out.InstallConfig
+		*out = new(InstallConfigStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImageRegistry != nil {
+		in, out := &in.ImageRegistry, &out.ImageRegistry
+		*out = new(ImageRegistryStatus)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KlusterletAddonConfigStatus.
@@ -203,9 +304,80 @@ func (in *KlusterletAddonConfigStatus) DeepCopy() *KlusterletAddonConfigStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KlusterletAddonStatus) DeepCopyInto(out *KlusterletAddonStatus) {
+	*out = *in
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KlusterletAddonStatus.
+func (in *KlusterletAddonStatus) DeepCopy() *KlusterletAddonStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KlusterletAddonStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacedConfigMapReference) DeepCopyInto(out *NamespacedConfigMapReference) {
+	*out = *in
+	out.ConfigMapReference = in.ConfigMapReference
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacedConfigMapReference.
+func (in *NamespacedConfigMapReference) DeepCopy() *NamespacedConfigMapReference {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacedConfigMapReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespacedSecretReference) DeepCopyInto(out *NamespacedSecretReference) {
+	*out = *in
+	out.SecretReference = in.SecretReference
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespacedSecretReference.
+func (in *NamespacedSecretReference) DeepCopy() *NamespacedSecretReference {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespacedSecretReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ProxyConfig) DeepCopyInto(out *ProxyConfig) {
 	*out = *in
+	if in.TrustedCABundle != nil {
+		in, out := &in.TrustedCABundle, &out.TrustedCABundle
+		*out = new(ConfigMapReference)
+		**out = **in
+	}
+	if in.CredentialsSecret != nil {
+		in, out := &in.CredentialsSecret, &out.CredentialsSecret
+		*out = new(SecretReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyConfig.
@@ -217,3 +389,128 @@ func (in *ProxyConfig) DeepCopy() *ProxyConfig {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxyProfile) DeepCopyInto(out *ProxyProfile) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyProfile.
+func (in *ProxyProfile) DeepCopy() *ProxyProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxyProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ProxyProfile) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxyProfileList) DeepCopyInto(out *ProxyProfileList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ProxyProfile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyProfileList.
+func (in *ProxyProfileList) DeepCopy() *ProxyProfileList {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxyProfileList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ProxyProfileList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxyProfileSpec) DeepCopyInto(out *ProxyProfileSpec) {
+	*out = *in
+	if in.TrustedCABundle != nil {
+		in, out := &in.TrustedCABundle, &out.TrustedCABundle
+		*out = new(NamespacedConfigMapReference)
+		**out = **in
+	}
+	if in.CredentialsSecret != nil {
+		in, out := &in.CredentialsSecret, &out.CredentialsSecret
+		*out = new(NamespacedSecretReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyProfileSpec.
+func (in *ProxyProfileSpec) DeepCopy() *ProxyProfileSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxyProfileSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretReference) DeepCopyInto(out *SecretReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretReference.
+func (in *SecretReference) DeepCopy() *SecretReference {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkManagerConfigSpec) DeepCopyInto(out *WorkManagerConfigSpec) {
+	*out = *in
+	if in.AdditionalNoProxy != nil {
+		in, out := &in.AdditionalNoProxy, &out.AdditionalNoProxy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ImageOverrides != nil {
+		in, out := &in.ImageOverrides, &out.ImageOverrides
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkManagerConfigSpec.
+func (in *WorkManagerConfigSpec) DeepCopy() *WorkManagerConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkManagerConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
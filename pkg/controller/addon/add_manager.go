@@ -1,24 +1,51 @@
 package addon
 
 import (
+	"context"
+
+	ocinfrav1 "github.com/openshift/api/config/v1"
+	imageregistryv1alpha1 "github.com/stolostron/cluster-lifecycle-api/imageregistry/v1alpha1"
 	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	managedclusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 func Add(mgr manager.Manager, kubeClient kubernetes.Interface) error {
-	return add(mgr, newReconciler(mgr))
+	// the klusterletAddonConfigs whose images are changed are requeued by the imageManifest controller
+	imageManifestEvents := make(chan event.GenericEvent)
+	if err := add(mgr, newReconciler(mgr), imageManifestEvents); err != nil {
+		return err
+	}
+	// the image manifests and the hub version are not synced in the standalone mode
+	if agentv1.IsStandaloneMode() {
+		klog.Infof("the controller runs in the standalone mode, skip watching the image manifests and the hub version")
+		return nil
+	}
+	if err := addImageManifest(mgr,
+		&ReconcileImageManifest{client: mgr.GetClient(), events: imageManifestEvents}); err != nil {
+		return err
+	}
+	return addHubVersion(mgr, &ReconcileHubVersion{client: mgr.GetClient(), events: imageManifestEvents})
 }
 
-func add(mgr manager.Manager, r reconcile.Reconciler) error {
+func add(mgr manager.Manager, r reconcile.Reconciler, imageManifestEvents <-chan event.GenericEvent) error {
 	c, err := controller.New("klusterletAddon-controller", mgr, controller.Options{Reconciler: r})
 	if err != nil {
 		return err
@@ -29,6 +56,11 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	err = c.Watch(&source.Channel{Source: imageManifestEvents}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
 	err = c.Watch(&source.Kind{Type: &managedclusterv1.ManagedCluster{}},
 		handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
 			return []reconcile.Request{
@@ -58,6 +90,371 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		}),
 
 		klusterletAddonPredicate())
+	if err != nil {
+		return err
+	}
+
+	// requeue the klusterletAddonConfigs which reference the trusted CA bundle configmap directly or by the
+	// proxyProfile. The events of the other configmaps on the hub are filtered out.
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}},
+		handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+			return trustedCABundleRequests(mgr.GetClient(), obj)
+		}),
+		referencedConfigMapPredicate(mgr.GetClient()),
+	)
+	if err != nil {
+		return err
+	}
+
+	// requeue the klusterletAddonConfigs whose addons use the image pull secret or the proxy credentials secret
+	// when it is rotated. The events of the other secrets on the hub are filtered out.
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}},
+		handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+			return append(imagePullSecretRequests(mgr.GetClient(), obj), proxyCredentialsRequests(mgr.GetClient(), obj)...)
+		}),
+		referencedSecretPredicate(mgr.GetClient()),
+	)
+	if err != nil {
+		return err
+	}
+
+	// requeue the klusterletAddonConfigs which reference the proxyProfile
+	err = c.Watch(&source.Kind{Type: &agentv1.ProxyProfile{}},
+		handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+			return proxyProfileRequests(mgr.GetClient(), obj)
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	// requeue all of the klusterletAddonConfigs when the API server URLs of the hub are changed, since they are added
+	// into the noProxy of the addons. The infrastructure is watched only if its CRD is installed on the hub.
+	infrastructureGVK, err := apiutil.GVKForObject(&ocinfrav1.Infrastructure{}, mgr.GetScheme())
+	if err != nil {
+		return err
+	}
+	_, err = mgr.GetRESTMapper().RESTMapping(infrastructureGVK.GroupKind(), infrastructureGVK.Version)
+	switch {
+	case meta.IsNoMatchError(err):
+		klog.Infof("%s is not installed, skip watching it", infrastructureGVK.Kind)
+	case err != nil:
+		return err
+	default:
+		err = c.Watch(&source.Kind{Type: &ocinfrav1.Infrastructure{}},
+			handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+				if obj.GetName() != infrastructureName {
+					return nil
+				}
+				return allKlusterletAddonConfigRequests(mgr.GetClient())
+			}),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// requeue the clusters selected by the managedClusterImageRegistries before and after they are changed. The
+	// managedClusterImageRegistries and the placementDecisions are watched only if their CRDs are installed on the hub.
+	for _, w := range []struct {
+		obj   client.Object
+		mapFn handler.MapFunc
+	}{
+		{
+			obj: &imageregistryv1alpha1.ManagedClusterImageRegistry{},
+			mapFn: func(obj client.Object) []reconcile.Request {
+				return imageRegistryRequests(mgr.GetClient(), obj)
+			},
+		},
+		{
+			obj: &clusterv1beta1.PlacementDecision{},
+			mapFn: func(obj client.Object) []reconcile.Request {
+				return placementDecisionRequests(mgr.GetClient(), obj)
+			},
+		},
+	} {
+		gvk, err := apiutil.GVKForObject(w.obj, mgr.GetScheme())
+		if err != nil {
+			return err
+		}
+		_, err = mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) {
+			klog.Infof("%s is not installed, skip watching it", gvk.Kind)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := c.Watch(&source.Kind{Type: w.obj}, clusterRequestsHandler(w.mapFn)); err != nil {
+			return err
+		}
+	}
+
+	// requeue all of the klusterletAddonConfigs when the mirror sets on the hub are changed. The mirror sets are
+	// watched only if the hub image mirrors are enabled and their CRDs are installed on the hub.
+	if hubImageMirrorsEnabled() {
+		for _, mirrorSet := range imageMirrorSets {
+			_, err = mgr.GetRESTMapper().RESTMapping(mirrorSet.gvk.GroupKind(), mirrorSet.gvk.Version)
+			if meta.IsNoMatchError(err) {
+				klog.Infof("%s is not installed, skip watching it", mirrorSet.gvk.Kind)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(mirrorSet.gvk)
+			err = c.Watch(&source.Kind{Type: obj},
+				handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+					return allKlusterletAddonConfigRequests(mgr.GetClient())
+				}),
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// requeue the self-managed clusters when the node placement of the MultiClusterHub is changed. The
+	// MultiClusterHub is not watched in the standalone mode or if its CRD is not installed on the hub.
+	if !agentv1.IsStandaloneMode() {
+		_, err = mgr.GetRESTMapper().RESTMapping(multiClusterHubGVK.GroupKind(), multiClusterHubGVK.Version)
+		switch {
+		case meta.IsNoMatchError(err):
+			klog.Infof("%s is not installed, skip watching it", multiClusterHubGVK.Kind)
+		case err != nil:
+			return err
+		default:
+			multiClusterHub := &unstructured.Unstructured{}
+			multiClusterHub.SetGroupVersionKind(multiClusterHubGVK)
+			err = c.Watch(&source.Kind{Type: multiClusterHub},
+				handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+					return localClusterRequests(mgr.GetClient())
+				}),
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// requeue the clusters which use the klusterletConfig. The klusterletConfig is watched only if its CRD is
+	// installed on the hub.
+	_, err = mgr.GetRESTMapper().RESTMapping(klusterletConfigGVK.GroupKind(), klusterletConfigGVK.Version)
+	if meta.IsNoMatchError(err) {
+		klog.Infof("%s is not installed, skip watching it", klusterletConfigGVK.Kind)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	klusterletConfig := &unstructured.Unstructured{}
+	klusterletConfig.SetGroupVersionKind(klusterletConfigGVK)
+	return c.Watch(&source.Kind{Type: klusterletConfig},
+		handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+			return klusterletConfigRequests(mgr.GetClient(), obj)
+		}),
+	)
+}
+
+func addImageManifest(mgr manager.Manager, r reconcile.Reconciler) error {
+	c, err := controller.New("imageManifest-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	return c.Watch(&source.Kind{Type: &corev1.ConfigMap{}},
+		handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+			return []reconcile.Request{imageManifestRequest}
+		}),
+		imageManifestPredicate())
+}
+
+// addHubVersion watches the MultiClusterHub to sync the hub version. The MultiClusterHub is not watched if the hub
+// version is set by the flag or the env, or the MultiClusterHub is not installed on the hub.
+func addHubVersion(mgr manager.Manager, r reconcile.Reconciler) error {
+	if agentv1.IsStaticHubVersion() {
+		klog.Infof("the hub version %s is static, skip watching %s", agentv1.HubVersion(), multiClusterHubGVK.Kind)
+		return nil
+	}
+
+	_, err := mgr.GetRESTMapper().RESTMapping(multiClusterHubGVK.GroupKind(), multiClusterHubGVK.Version)
+	if meta.IsNoMatchError(err) {
+		klog.Infof("%s is not installed, skip watching it", multiClusterHubGVK.Kind)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	c, err := controller.New("hubVersion-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+
+	multiClusterHub := &unstructured.Unstructured{}
+	multiClusterHub.SetGroupVersionKind(multiClusterHubGVK)
+	return c.Watch(&source.Kind{Type: multiClusterHub},
+		handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+			return []reconcile.Request{hubVersionRequest}
+		}),
+	)
+}
+
+func klusterletConfigRequests(c client.Client, klusterletConfig client.Object) []reconcile.Request {
+	clusterList := &managedclusterv1.ManagedClusterList{}
+	if err := c.List(context.TODO(), clusterList); err != nil {
+		klog.Errorf("failed to list managedClusters. err:%v", err)
+		return nil
+	}
 
-	return err
+	var requests []reconcile.Request
+	for _, cluster := range clusterList.Items {
+		if klusterletConfigName(&cluster) != klusterletConfig.GetName() {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: cluster.Name, Namespace: cluster.Name},
+		})
+	}
+	return requests
+}
+
+func proxyProfileRequests(c client.Client, proxyProfile client.Object) []reconcile.Request {
+	configList := &agentv1.KlusterletAddonConfigList{}
+	if err := c.List(context.TODO(), configList); err != nil {
+		klog.Errorf("failed to list klusterletAddonConfigs. err:%v", err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, config := range configList.Items {
+		if config.Spec.ProxyProfile != proxyProfile.GetName() {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: config.Name, Namespace: config.Namespace},
+		})
+	}
+	return requests
+}
+
+// referencedConfigMapPredicate filters the configmaps which are referenced as the trusted CA bundle.
+func referencedConfigMapPredicate(c client.Client) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return len(trustedCABundleRequests(c, obj)) != 0
+	})
+}
+
+// referencedSecretPredicate filters the secrets which are referenced as the image pull secret or the proxy
+// credentials secret.
+func referencedSecretPredicate(c client.Client) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return isImagePullSecret(c, obj) || len(proxyCredentialsRequests(c, obj)) != 0
+	})
+}
+
+func trustedCABundleRequests(c client.Client, configMap client.Object) []reconcile.Request {
+	return proxyReferenceRequests(c, configMap,
+		func(config *agentv1.KlusterletAddonConfig) []string {
+			var names []string
+			for _, ref := range []*agentv1.ConfigMapReference{
+				config.Spec.ProxyConfig.TrustedCABundle,
+				config.Status.OCPGlobalProxy.TrustedCABundle,
+			} {
+				if ref != nil {
+					names = append(names, ref.Name)
+				}
+			}
+			return names
+		},
+		func(profile *agentv1.ProxyProfile) *types.NamespacedName {
+			if ref := profile.Spec.TrustedCABundle; ref != nil {
+				return &types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}
+			}
+			return nil
+		},
+	)
+}
+
+func proxyCredentialsRequests(c client.Client, secret client.Object) []reconcile.Request {
+	return proxyReferenceRequests(c, secret,
+		func(config *agentv1.KlusterletAddonConfig) []string {
+			var names []string
+			for _, ref := range []*agentv1.SecretReference{
+				config.Spec.ProxyConfig.CredentialsSecret,
+				config.Status.OCPGlobalProxy.CredentialsSecret,
+			} {
+				if ref != nil {
+					names = append(names, ref.Name)
+				}
+			}
+			return names
+		},
+		func(profile *agentv1.ProxyProfile) *types.NamespacedName {
+			if ref := profile.Spec.CredentialsSecret; ref != nil {
+				return &types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}
+			}
+			return nil
+		},
+	)
+}
+
+// proxyReferenceRequests returns the requests of the klusterletAddonConfigs which reference the object by their
+// proxy configs or by their proxyProfiles. configRefs returns the names of the objects referenced by the
+// klusterletAddonConfig in its namespace, and profileRef returns the object referenced by the proxyProfile, whose
+// empty namespace is the namespace of each klusterletAddonConfig referencing the proxyProfile.
+func proxyReferenceRequests(c client.Client, obj client.Object,
+	configRefs func(config *agentv1.KlusterletAddonConfig) []string,
+	profileRef func(profile *agentv1.ProxyProfile) *types.NamespacedName) []reconcile.Request {
+	profileList := &agentv1.ProxyProfileList{}
+	if err := c.List(context.TODO(), profileList); err != nil {
+		klog.Errorf("failed to list proxyProfiles. err:%v", err)
+	}
+	// the proxyProfiles referencing the object with its namespace, and the proxyProfiles referencing the object
+	// in the namespace of each klusterletAddonConfig
+	namespacedProfiles, localProfiles := sets.NewString(), sets.NewString()
+	for i := range profileList.Items {
+		ref := profileRef(&profileList.Items[i])
+		if ref == nil || ref.Name != obj.GetName() {
+			continue
+		}
+		switch ref.Namespace {
+		case "":
+			localProfiles.Insert(profileList.Items[i].Name)
+		case obj.GetNamespace():
+			namespacedProfiles.Insert(profileList.Items[i].Name)
+		}
+	}
+
+	// the object can only be referenced by the klusterletAddonConfigs in its namespace unless a proxyProfile
+	// references it with its namespace
+	var listOpts []client.ListOption
+	if namespacedProfiles.Len() == 0 {
+		listOpts = append(listOpts, client.InNamespace(obj.GetNamespace()))
+	}
+	configList := &agentv1.KlusterletAddonConfigList{}
+	if err := c.List(context.TODO(), configList, listOpts...); err != nil {
+		klog.Errorf("failed to list klusterletAddonConfigs. err:%v", err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range configList.Items {
+		config := &configList.Items[i]
+		referenced := namespacedProfiles.Has(config.Spec.ProxyProfile)
+		if config.Namespace == obj.GetNamespace() {
+			referenced = referenced || localProfiles.Has(config.Spec.ProxyProfile) ||
+				sets.NewString(configRefs(config)...).Has(obj.GetName())
+		}
+		if !referenced {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: config.Name, Namespace: config.Namespace},
+		})
+	}
+	return requests
 }
@@ -0,0 +1,58 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+
+	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var multiClusterHubGVK = schema.GroupVersionKind{
+	Group:   agentv1.MCHgvr.Group,
+	Version: agentv1.MCHgvr.Version,
+	Kind:    "MultiClusterHub",
+}
+
+// hubVersionRequest is the only request of the hubVersion controller, all of the MultiClusterHubs are
+// checked together.
+var hubVersionRequest = reconcile.Request{NamespacedName: types.NamespacedName{Name: "hub-version"}}
+
+// ReconcileHubVersion syncs the hub version from the current version of the MultiClusterHub, and requeues all of
+// the klusterletAddonConfigs when the hub version is changed.
+type ReconcileHubVersion struct {
+	client client.Client
+	// events is the channel of the klusterletAddonConfigs which are requeued to the klusterletAddon controller
+	events chan<- event.GenericEvent
+}
+
+func (r *ReconcileHubVersion) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	mchList := &unstructured.UnstructuredList{}
+	mchList.SetGroupVersionKind(multiClusterHubGVK.GroupVersion().WithKind(multiClusterHubGVK.Kind + "List"))
+	if err := r.client.List(ctx, mchList); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	hubVersion, err := agentv1.MultiClusterHubVersion(mchList.Items)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	// the hub version is kept if the MultiClusterHub is deleted or it is not installed completely
+	if hubVersion == "" || hubVersion == agentv1.HubVersion() {
+		return reconcile.Result{}, nil
+	}
+
+	klog.Infof("the hub version is changed from %s to %s", agentv1.HubVersion(), hubVersion)
+	agentv1.SetHubVersion(hubVersion)
+
+	// all of the clusters are rendered again since the image manifest version in the status may be changed
+	return reconcile.Result{}, requeueKlusterletAddonConfigs(ctx, r.client, r.events,
+		func(config *agentv1.KlusterletAddonConfig) bool { return true })
+}
@@ -0,0 +1,83 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stolostron/klusterlet-addon-controller/pkg/apis"
+	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func newMultiClusterHub(currentVersion string) *unstructured.Unstructured {
+	mch := &unstructured.Unstructured{}
+	mch.SetGroupVersionKind(multiClusterHubGVK)
+	mch.SetNamespace("open-cluster-management")
+	mch.SetName("multiclusterhub")
+	if currentVersion != "" {
+		_ = unstructured.SetNestedField(mch.Object, currentVersion, "status", "currentVersion")
+	}
+	return mch
+}
+
+func Test_ReconcileHubVersion(t *testing.T) {
+	testscheme := scheme.Scheme
+	_ = apis.AddToScheme(testscheme)
+
+	tests := []struct {
+		name               string
+		hubVersion         string
+		mch                *unstructured.Unstructured
+		expectedHubVersion string
+		expectedRequeued   int
+	}{
+		{
+			name:               "hub is upgraded",
+			hubVersion:         "2.8.0",
+			mch:                newMultiClusterHub("2.9.0"),
+			expectedHubVersion: "2.9.0",
+			expectedRequeued:   2,
+		},
+		{
+			name:               "hub version is not changed",
+			hubVersion:         "2.9.0",
+			mch:                newMultiClusterHub("2.9.0"),
+			expectedHubVersion: "2.9.0",
+		},
+		{
+			name:               "mch is installing",
+			hubVersion:         "2.8.0",
+			mch:                newMultiClusterHub(""),
+			expectedHubVersion: "2.8.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agentv1.SetHubVersion(tt.hubVersion)
+			defer agentv1.SetHubVersion("")
+
+			client := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(
+				tt.mch, newKlusterletAddonConfig("cluster1"), newKlusterletAddonConfig("cluster2"),
+			).Build()
+			events := make(chan event.GenericEvent, 2)
+			r := &ReconcileHubVersion{client: client, events: events}
+			if _, err := r.Reconcile(context.TODO(), hubVersionRequest); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			close(events)
+
+			if hubVersion := agentv1.HubVersion(); hubVersion != tt.expectedHubVersion {
+				t.Errorf("expected hub version %q, but got %q", tt.expectedHubVersion, hubVersion)
+			}
+			if len(events) != tt.expectedRequeued {
+				t.Errorf("expected %d klusterletAddonConfigs are requeued, but got %d", tt.expectedRequeued, len(events))
+			}
+		})
+	}
+}
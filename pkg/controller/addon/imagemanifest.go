@@ -0,0 +1,122 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+
+	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// imageManifestRequest is the only request of the imageManifest controller, all of the image-manifest configmaps
+// are reloaded together.
+var imageManifestRequest = reconcile.Request{NamespacedName: types.NamespacedName{Name: agentv1.ImageManifestType}}
+
+// ReconcileImageManifest reloads the image manifests when the image-manifest configmaps are changed, and requeues
+// the klusterletAddonConfigs whose addons use the changed images.
+type ReconcileImageManifest struct {
+	client client.Client
+	// events is the channel of the klusterletAddonConfigs which are requeued to the klusterletAddon controller
+	events chan<- event.GenericEvent
+}
+
+func (r *ReconcileImageManifest) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	previousManifestVersion, _ := agentv1.GetImageManifestVersion()
+	changedImageKeys, err := agentv1.ReloadConfigmaps(ctx, r.client)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	manifestVersion, _ := agentv1.GetImageManifestVersion()
+
+	// all of the clusters are requeued to update the image manifest version in the status
+	if manifestVersion != previousManifestVersion {
+		return reconcile.Result{}, requeueKlusterletAddonConfigs(ctx, r.client, r.events,
+			func(config *agentv1.KlusterletAddonConfig) bool { return true })
+	}
+	if len(changedImageKeys) != 0 {
+		klog.Infof("the images %v in the image manifest are changed", changedImageKeys)
+	}
+
+	// the clusters with pinned image manifest versions are requeued since the pinned image manifests may be changed
+	changed := sets.NewString(changedImageKeys...)
+	return reconcile.Result{}, requeueKlusterletAddonConfigs(ctx, r.client, r.events,
+		func(config *agentv1.KlusterletAddonConfig) bool {
+			return hasPinnedManifestVersion(config) || usesImages(config, changed)
+		})
+}
+
+// requeueKlusterletAddonConfigs sends the klusterletAddonConfigs which are matched by the filter to the events.
+func requeueKlusterletAddonConfigs(ctx context.Context, c client.Client, events chan<- event.GenericEvent,
+	filter func(config *agentv1.KlusterletAddonConfig) bool) error {
+	configList := &agentv1.KlusterletAddonConfigList{}
+	if err := c.List(ctx, configList); err != nil {
+		return err
+	}
+
+	for i := range configList.Items {
+		config := &configList.Items[i]
+		if !filter(config) {
+			continue
+		}
+		select {
+		case events <- event.GenericEvent{Object: config}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// usesImages returns true if one of the enabled addons of the klusterletAddonConfig uses one of the image keys.
+func usesImages(config *agentv1.KlusterletAddonConfig, imageKeys sets.String) bool {
+	for addonName := range agentv1.KlusterletAddons {
+		if !addonIsManaged(addonName, config) || !addonIsEnabled(addonName, config) {
+			continue
+		}
+		if imageKeys.HasAny(agentv1.KlusterletAddonImageNames[addonName]...) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPinnedManifestVersion returns true if one of the enabled addons of the klusterletAddonConfig is pinned to an
+// image manifest version.
+func hasPinnedManifestVersion(config *agentv1.KlusterletAddonConfig) bool {
+	for addonName := range agentv1.KlusterletAddons {
+		if addonIsManaged(addonName, config) && addonIsEnabled(addonName, config) &&
+			getAddonManifestVersion(addonName, config) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// imageManifestPredicate filters the image-manifest configmaps, a configmap which is labeled or unlabeled is
+// also an image-manifest change.
+func imageManifestPredicate() predicate.Predicate {
+	isImageManifest := func(obj client.Object) bool {
+		return obj.GetLabels()[agentv1.ImageManifestTypeLabel] == agentv1.ImageManifestType
+	}
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return isImageManifest(e.Object)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return isImageManifest(e.Object)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return isImageManifest(e.ObjectOld) || isImageManifest(e.ObjectNew)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return isImageManifest(e.Object)
+		},
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stolostron/klusterlet-addon-controller/pkg/apis"
+	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func newImageManifestConfigMap(images map[string]string) *corev1.ConfigMap {
+	return newImageManifestConfigMapOfVersion("x.y.z", images)
+}
+
+func newImageManifestConfigMapOfVersion(version string, images map[string]string) *corev1.ConfigMap {
+	name := "image-manifest"
+	if version != "x.y.z" {
+		name = "image-manifest-" + version
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "open-cluster-management",
+			Labels: map[string]string{
+				agentv1.ImageManifestTypeLabel: agentv1.ImageManifestType,
+				"ocm-release-version":          version,
+			},
+		},
+		Data: images,
+	}
+}
+
+// loadImageManifests loads the image manifests of each version with the images, the hub version is x.y.z. The
+// loaded image manifests are removed when the test finishes.
+func loadImageManifests(t *testing.T, manifests map[string]map[string]string) {
+	agentv1.SetHubVersion("x.y.z")
+	builder := fake.NewClientBuilder().WithScheme(scheme.Scheme)
+	for version, images := range manifests {
+		builder = builder.WithObjects(newImageManifestConfigMapOfVersion(version, images))
+	}
+	if err := agentv1.LoadConfigmaps(builder.Build()); err != nil {
+		t.Fatalf("failed to load configmaps. err: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := agentv1.LoadConfigmaps(fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()); err != nil {
+			t.Errorf("failed to unload configmaps. err: %v", err)
+		}
+		agentv1.SetHubVersion("")
+	})
+}
+
+func Test_ReconcileImageManifest(t *testing.T) {
+	testscheme := scheme.Scheme
+	_ = apis.AddToScheme(testscheme)
+	loadImageManifests(t, map[string]map[string]string{
+		"x.y.z": {"search_collector": "quay.io/stolostron/search-collector:1"},
+	})
+
+	searchConfig := newKlusterletAddonConfig("cluster1")
+	searchConfig.Spec.SearchCollectorConfig.Enabled = true
+	appConfig := newKlusterletAddonConfig("cluster2")
+	appConfig.Spec.SearchCollectorConfig.Enabled = false
+
+	client := fake.NewClientBuilder().WithScheme(testscheme).WithObjects(
+		newImageManifestConfigMap(map[string]string{"search_collector": "quay.io/stolostron/search-collector:1"}),
+		searchConfig, appConfig,
+	).Build()
+	err := client.Update(context.TODO(),
+		newImageManifestConfigMap(map[string]string{"search_collector": "quay.io/stolostron/search-collector:2"}))
+	if err != nil {
+		t.Fatalf("failed to update configmap. err: %v", err)
+	}
+
+	events := make(chan event.GenericEvent, 2)
+	r := &ReconcileImageManifest{client: client, events: events}
+	if _, err := r.Reconcile(context.TODO(), imageManifestRequest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(events)
+
+	requeued := sets.NewString()
+	for e := range events {
+		requeued.Insert(e.Object.GetNamespace() + "/" + e.Object.GetName())
+	}
+	if !requeued.Equal(sets.NewString("cluster1/cluster1")) {
+		t.Errorf("expected cluster1 is requeued, but got %v", requeued.List())
+	}
+}
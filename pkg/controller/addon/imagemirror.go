@@ -0,0 +1,153 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/stolostron/cluster-lifecycle-api/helpers/imageregistry"
+	imageregistryv1alpha1 "github.com/stolostron/cluster-lifecycle-api/imageregistry/v1alpha1"
+	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// hubImageMirrorsEnv is the env name of the flag to rewrite the images of the addons with the registry mirrors of
+// the ImageContentSourcePolicies and ImageDigestMirrorSets on the hub. The images of the clusters with the
+// ClusterImageRegistries annotation are not rewritten by the hub mirrors.
+const hubImageMirrorsEnv = "ENABLE_HUB_IMAGE_MIRRORS"
+
+var imageContentSourcePolicyGVK = schema.GroupVersionKind{
+	Group:   "operator.openshift.io",
+	Version: "v1alpha1",
+	Kind:    "ImageContentSourcePolicy",
+}
+
+var imageDigestMirrorSetGVK = schema.GroupVersionKind{
+	Group:   "config.openshift.io",
+	Version: "v1",
+	Kind:    "ImageDigestMirrorSet",
+}
+
+// imageMirrorSets are the mirror sets on the hub and the field paths of their mirrors. The mirrors of the
+// ImageDigestMirrorSets are applied after the ImageContentSourcePolicies, so they win if the sources are the same.
+var imageMirrorSets = []struct {
+	gvk    schema.GroupVersionKind
+	fields []string
+}{
+	{gvk: imageContentSourcePolicyGVK, fields: []string{"spec", "repositoryDigestMirrors"}},
+	{gvk: imageDigestMirrorSetGVK, fields: []string{"spec", "imageDigestMirrors"}},
+}
+
+func hubImageMirrorsEnabled() bool {
+	return strings.EqualFold(os.Getenv(hubImageMirrorsEnv), "true")
+}
+
+// hubImageMirrors rewrites the images with the registry mirrors of the hub. A nil hubImageMirrors does not
+// rewrite the images.
+type hubImageMirrors struct {
+	// annotations contains the ClusterImageRegistries annotation built from the mirrors, so the images are rewritten
+	// the same as the images of the clusters with the annotation.
+	annotations map[string]string
+}
+
+// overrideImage rewrites the image with the hub mirrors. The mirrors of the mirror sets only apply to the images
+// pulled by digest, so the images referenced by tag are not rewritten.
+func (m *hubImageMirrors) overrideImage(image string) (string, error) {
+	if m == nil || !strings.Contains(image, "@") {
+		return image, nil
+	}
+	return imageregistry.OverrideImageByAnnotation(m.annotations, image)
+}
+
+// getHubImageMirrors returns the registry mirrors of the mirror sets on the hub. nil is returned if it is not
+// enabled or there is no mirror on the hub. The first mirror of each source is used.
+func (r *ReconcileKlusterletAddOn) getHubImageMirrors(ctx context.Context) (*hubImageMirrors, error) {
+	if !hubImageMirrorsEnabled() {
+		return nil, nil
+	}
+
+	var registries []imageregistryv1alpha1.Registries
+	for _, mirrorSet := range imageMirrorSets {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(mirrorSet.gvk.GroupVersion().WithKind(mirrorSet.gvk.Kind + "List"))
+		err := r.client.List(ctx, list)
+		if meta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s. err:%v", mirrorSet.gvk.Kind, err)
+		}
+
+		items := list.Items
+		sort.Slice(items, func(i, j int) bool { return items[i].GetName() < items[j].GetName() })
+		for _, item := range items {
+			mirrors, _, err := unstructured.NestedSlice(item.Object, mirrorSet.fields...)
+			if err != nil {
+				return nil, fmt.Errorf("invalid mirrors in the %s %s. err:%v", mirrorSet.gvk.Kind, item.GetName(), err)
+			}
+			for _, mirror := range mirrors {
+				registry, ok := imageRegistry(mirror)
+				if !ok {
+					continue
+				}
+				registries = append(registries, registry)
+			}
+		}
+	}
+	if len(registries) == 0 {
+		return nil, nil
+	}
+
+	imageRegistries, err := json.Marshal(imageregistryv1alpha1.ImageRegistries{Registries: registries})
+	if err != nil {
+		return nil, err
+	}
+	return &hubImageMirrors{
+		annotations: map[string]string{
+			imageregistryv1alpha1.ClusterImageRegistriesAnnotation: string(imageRegistries),
+		},
+	}, nil
+}
+
+// imageRegistry returns the registry of the first mirror of a mirror set entry.
+func imageRegistry(mirror interface{}) (imageregistryv1alpha1.Registries, bool) {
+	entry, ok := mirror.(map[string]interface{})
+	if !ok {
+		return imageregistryv1alpha1.Registries{}, false
+	}
+	source, _, _ := unstructured.NestedString(entry, "source")
+	mirrors, _, _ := unstructured.NestedStringSlice(entry, "mirrors")
+	if source == "" || len(mirrors) == 0 || mirrors[0] == "" {
+		return imageregistryv1alpha1.Registries{}, false
+	}
+	return imageregistryv1alpha1.Registries{Source: source, Mirror: mirrors[0]}, true
+}
+
+// allKlusterletAddonConfigRequests requeues all of the klusterletAddonConfigs, like when a mirror set on the hub is
+// changed.
+func allKlusterletAddonConfigRequests(c client.Client) []reconcile.Request {
+	configList := &agentv1.KlusterletAddonConfigList{}
+	if err := c.List(context.TODO(), configList); err != nil {
+		klog.Errorf("failed to list klusterletAddonConfigs. err:%v", err)
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(configList.Items))
+	for _, config := range configList.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: config.Name, Namespace: config.Namespace},
+		})
+	}
+	return requests
+}
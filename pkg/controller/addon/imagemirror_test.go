@@ -0,0 +1,105 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newImageMirrorSet(gvk schema.GroupVersionKind, name, field string, mirrors ...interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"spec": map[string]interface{}{field: mirrors},
+		},
+	}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName(name)
+	return obj
+}
+
+func Test_getHubImageMirrors(t *testing.T) {
+	icsp := newImageMirrorSet(imageContentSourcePolicyGVK, "icsp", "repositoryDigestMirrors",
+		map[string]interface{}{
+			"source":  "quay.io/stolostron",
+			"mirrors": []interface{}{"icsp-mirror.example.com/stolostron"},
+		},
+		map[string]interface{}{
+			"source":  "registry.redhat.io/rhacm2",
+			"mirrors": []interface{}{"icsp-mirror.example.com/rhacm2", "icsp-mirror2.example.com/rhacm2"},
+		},
+	)
+	idms := newImageMirrorSet(imageDigestMirrorSetGVK, "idms", "imageDigestMirrors",
+		map[string]interface{}{
+			"source":  "quay.io/stolostron",
+			"mirrors": []interface{}{"idms-mirror.example.com/stolostron"},
+		},
+		map[string]interface{}{
+			"source": "registry.example.com/no-mirror",
+		},
+	)
+
+	tests := []struct {
+		name           string
+		enabled        string
+		objs           []runtime.Object
+		images         map[string]string
+		expectedMirror bool
+	}{
+		{
+			name:    "disabled",
+			enabled: "false",
+			objs:    []runtime.Object{icsp, idms},
+		},
+		{
+			name:    "no mirror sets",
+			enabled: "true",
+		},
+		{
+			name:           "mirror sets",
+			enabled:        "true",
+			objs:           []runtime.Object{icsp, idms},
+			expectedMirror: true,
+			images: map[string]string{
+				"quay.io/stolostron/search-collector@sha256:abc":     "idms-mirror.example.com/stolostron/search-collector@sha256:abc",
+				"registry.redhat.io/rhacm2/config-policy@sha256:def": "icsp-mirror.example.com/rhacm2/config-policy@sha256:def",
+				"registry.redhat.io/rhacm2/config-policy:v2.9":       "registry.redhat.io/rhacm2/config-policy:v2.9",
+				"registry.example.com/no-mirror/image@sha256:ghi":    "registry.example.com/no-mirror/image@sha256:ghi",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(hubImageMirrorsEnv, tt.enabled)
+
+			// a new scheme is used since the fake client registers the unstructured mirror sets to the scheme
+			r := &ReconcileKlusterletAddOn{
+				client: fake.NewClientBuilder().WithScheme(runtime.NewScheme()).WithRuntimeObjects(tt.objs...).Build(),
+			}
+			mirrors, err := r.getHubImageMirrors(context.TODO())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (mirrors != nil) != tt.expectedMirror {
+				t.Fatalf("expected mirrors %v, but got %v", tt.expectedMirror, mirrors)
+			}
+
+			images := map[string]string{}
+			for image := range tt.images {
+				if images[image], err = mirrors.overrideImage(image); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+			if len(tt.images) != 0 && !reflect.DeepEqual(images, tt.images) {
+				t.Errorf("expected images %v, but got %v", tt.images, images)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// allowedImageRegistriesEnv is the env name of the comma-separated registries, like registry.example.com or
+	// registry.example.com/acm, which the image overrides and the rewritten images of the addons must be pulled from.
+	allowedImageRegistriesEnv = "ALLOWED_IMAGE_REGISTRIES"
+
+	// requireImageDigestEnv is the env name of the flag to require the image overrides and the rewritten images of
+	// the addons to be referenced by digest.
+	requireImageDigestEnv = "REQUIRE_IMAGE_DIGEST"
+)
+
+// imagePolicy is the policy of the hub for the images which are not the images of the image manifest, like the
+// image overrides and the images rewritten by the image registries. A nil imagePolicy allows all of the images.
+type imagePolicy struct {
+	allowedRegistries []string
+	requireDigest     bool
+}
+
+// getImagePolicy returns the image policy configured by the envs. nil is returned if there is no policy.
+func getImagePolicy() *imagePolicy {
+	policy := &imagePolicy{requireDigest: strings.EqualFold(os.Getenv(requireImageDigestEnv), "true")}
+	for _, registry := range strings.Split(os.Getenv(allowedImageRegistriesEnv), ",") {
+		registry = strings.TrimSuffix(strings.TrimSpace(registry), "/")
+		if registry == "" {
+			continue
+		}
+		policy.allowedRegistries = append(policy.allowedRegistries, registry)
+	}
+	if len(policy.allowedRegistries) == 0 && !policy.requireDigest {
+		return nil
+	}
+	return policy
+}
+
+// validate returns an imageNotAllowedError if the image is not allowed by the policy.
+func (p *imagePolicy) validate(image string) error {
+	if p == nil {
+		return nil
+	}
+	if p.requireDigest && !strings.Contains(image, "@sha256:") {
+		return &imageNotAllowedError{image: image, reason: "the image is not referenced by digest"}
+	}
+	if len(p.allowedRegistries) == 0 {
+		return nil
+	}
+	for _, registry := range p.allowedRegistries {
+		if strings.HasPrefix(image, registry+"/") {
+			return nil
+		}
+	}
+	return &imageNotAllowedError{
+		image:  image,
+		reason: fmt.Sprintf("the image is not from the allowed registries %v", p.allowedRegistries),
+	}
+}
+
+// imageNotAllowedError is the error of an image which is not allowed by the image policy of the hub.
+type imageNotAllowedError struct {
+	image  string
+	reason string
+}
+
+func (e *imageNotAllowedError) Error() string {
+	return fmt.Sprintf("image %s is not allowed, %s", e.image, e.reason)
+}
+
+func isImageNotAllowed(err error) bool {
+	var notAllowedErr *imageNotAllowedError
+	return errors.As(err, &notAllowedErr)
+}
+
+// getImagesAllowedCondition returns the ImagesAllowed condition of the klusterletAddonConfig from the errors of the
+// images which are not allowed. nil is returned if there is no image policy.
+func getImagesAllowedCondition(policy *imagePolicy, notAllowedErrs []error) *metav1.Condition {
+	if policy == nil {
+		return nil
+	}
+	if len(notAllowedErrs) == 0 {
+		return &metav1.Condition{
+			Type:    agentv1.ImagesAllowed,
+			Status:  metav1.ConditionTrue,
+			Reason:  agentv1.ReasonImagesAllowedByPolicy,
+			Message: "The images of the addons are allowed by the image policy",
+		}
+	}
+
+	messages := make([]string, 0, len(notAllowedErrs))
+	for _, err := range notAllowedErrs {
+		messages = append(messages, err.Error())
+	}
+	sort.Strings(messages)
+	return &metav1.Condition{
+		Type:    agentv1.ImagesAllowed,
+		Status:  metav1.ConditionFalse,
+		Reason:  agentv1.ReasonImagesNotAllowedByPolicy,
+		Message: strings.Join(messages, "; "),
+	}
+}
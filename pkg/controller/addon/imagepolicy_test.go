@@ -0,0 +1,63 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"testing"
+)
+
+func Test_imagePolicy(t *testing.T) {
+	tests := []struct {
+		name              string
+		allowedRegistries string
+		requireDigest     string
+		images            map[string]bool
+	}{
+		{
+			name: "no policy",
+			images: map[string]bool{
+				"evil.example.com/image:latest": true,
+			},
+		},
+		{
+			name:              "allowed registries",
+			allowedRegistries: " registry.example.com/acm/ , quay.io/stolostron",
+			images: map[string]bool{
+				"registry.example.com/acm/search-collector:2.9":      true,
+				"quay.io/stolostron/search-collector@sha256:abc":     true,
+				"quay.io/stolostron-evil/search-collector:2.9":       false,
+				"registry.example.com/other/search-collector:2.9":    false,
+				"registry.example.com.evil.com/acm/search-collector": false,
+			},
+		},
+		{
+			name:          "require digest",
+			requireDigest: "true",
+			images: map[string]bool{
+				"quay.io/stolostron/search-collector@sha256:abc": true,
+				"quay.io/stolostron/search-collector:2.9":        false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(allowedImageRegistriesEnv, tt.allowedRegistries)
+			t.Setenv(requireImageDigestEnv, tt.requireDigest)
+
+			policy := getImagePolicy()
+			if (policy == nil) != (tt.allowedRegistries == "" && tt.requireDigest == "") {
+				t.Errorf("unexpected image policy %v", policy)
+			}
+			for image, allowed := range tt.images {
+				err := policy.validate(image)
+				if allowed && err != nil {
+					t.Errorf("expected image %s is allowed, but got %v", image, err)
+				}
+				if !allowed && !isImageNotAllowed(err) {
+					t.Errorf("expected image %s is not allowed, but got %v", image, err)
+				}
+			}
+		})
+	}
+}
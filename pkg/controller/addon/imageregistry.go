@@ -0,0 +1,267 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	imageregistryv1alpha1 "github.com/stolostron/cluster-lifecycle-api/imageregistry/v1alpha1"
+	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+	managedclusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// clusterImageRegistry is the ManagedClusterImageRegistry whose placement selects the ManagedCluster.
+type clusterImageRegistry struct {
+	namespace string
+	name      string
+	// imageRegistries is the ClusterImageRegistries annotation built from the registries and the pull secret.
+	imageRegistries imageregistryv1alpha1.ImageRegistries
+}
+
+// status returns the status of the klusterletAddonConfig which reports the applied ManagedClusterImageRegistry.
+func (r *clusterImageRegistry) status() *agentv1.ImageRegistryStatus {
+	if r == nil {
+		return nil
+	}
+	return &agentv1.ImageRegistryStatus{
+		Name:       r.name,
+		Namespace:  r.namespace,
+		PullSecret: r.imageRegistries.PullSecret,
+	}
+}
+
+// condition returns the condition of the ManagedClusterImageRegistry applied to the ManagedCluster. The condition is
+// False if the ManagedCluster has the ClusterImageRegistries annotation, like a stale annotation left by a
+// ManagedClusterImageRegistry which no longer selects the cluster, but no ManagedClusterImageRegistry selects it.
+// nil is returned if the images are not rewritten by the image registries.
+func (r *clusterImageRegistry) condition(managedCluster *managedclusterv1.ManagedCluster) *metav1.Condition {
+	if r != nil {
+		return &metav1.Condition{
+			Type:    agentv1.ImageRegistryApplied,
+			Status:  metav1.ConditionTrue,
+			Reason:  agentv1.ReasonManagedClusterImageRegistrySelected,
+			Message: fmt.Sprintf("The managedClusterImageRegistry %s/%s is applied", r.namespace, r.name),
+		}
+	}
+	if _, ok := managedCluster.GetAnnotations()[imageregistryv1alpha1.ClusterImageRegistriesAnnotation]; !ok {
+		return nil
+	}
+	return &metav1.Condition{
+		Type:   agentv1.ImageRegistryApplied,
+		Status: metav1.ConditionFalse,
+		Reason: agentv1.ReasonImageRegistryAnnotationNotSelected,
+		Message: fmt.Sprintf("The cluster is not selected by any managedClusterImageRegistry, the images are "+
+			"rewritten by the annotation %s which may be stale", imageregistryv1alpha1.ClusterImageRegistriesAnnotation),
+	}
+}
+
+// apply returns a copy of the ManagedCluster whose ClusterImageRegistries annotation is replaced by the registries of
+// the ManagedClusterImageRegistry, so the images are not rewritten by a stale annotation.
+func (r *clusterImageRegistry) apply(managedCluster *managedclusterv1.ManagedCluster) (
+	*managedclusterv1.ManagedCluster, error) {
+	if r == nil {
+		return managedCluster, nil
+	}
+
+	annotation, ok := managedCluster.GetAnnotations()[imageregistryv1alpha1.ClusterImageRegistriesAnnotation]
+	existing := imageregistryv1alpha1.ImageRegistries{}
+	if !ok || json.Unmarshal([]byte(annotation), &existing) != nil ||
+		!equality.Semantic.DeepEqual(existing, r.imageRegistries) {
+		klog.Warningf("the annotation %s of cluster %s does not match the managedClusterImageRegistry %s/%s, "+
+			"the managedClusterImageRegistry is applied", imageregistryv1alpha1.ClusterImageRegistriesAnnotation,
+			managedCluster.GetName(), r.namespace, r.name)
+	}
+
+	imageRegistries, err := json.Marshal(r.imageRegistries)
+	if err != nil {
+		return nil, err
+	}
+	cluster := managedCluster.DeepCopy()
+	annotations := cluster.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[imageregistryv1alpha1.ClusterImageRegistriesAnnotation] = string(imageRegistries)
+	cluster.SetAnnotations(annotations)
+	return cluster, nil
+}
+
+// getClusterImageRegistry returns the ManagedClusterImageRegistry whose placement selects the ManagedCluster. If
+// there are multiple ManagedClusterImageRegistries selecting the ManagedCluster, they are sorted by namespace and
+// name and the first one is returned. nil is returned if there is no ManagedClusterImageRegistry selecting the
+// ManagedCluster or its CRD is not installed on the hub.
+func (r *ReconcileKlusterletAddOn) getClusterImageRegistry(ctx context.Context,
+	managedCluster *managedclusterv1.ManagedCluster) (*clusterImageRegistry, error) {
+	registryList := &imageregistryv1alpha1.ManagedClusterImageRegistryList{}
+	err := r.client.List(ctx, registryList)
+	if meta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list managedClusterImageRegistries. err:%v", err)
+	}
+
+	registries := registryList.Items
+	sort.Slice(registries, func(i, j int) bool {
+		if registries[i].Namespace != registries[j].Namespace {
+			return registries[i].Namespace < registries[j].Namespace
+		}
+		return registries[i].Name < registries[j].Name
+	})
+
+	var selected *clusterImageRegistry
+	for i := range registries {
+		registry := &registries[i]
+		clusterNames, err := placementClusterNames(ctx, r.client, registry.Namespace, registry.Spec.PlacementRef.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !clusterNames[managedCluster.GetName()] {
+			continue
+		}
+		if selected != nil {
+			klog.Warningf("the cluster %s is selected by multiple managedClusterImageRegistries, %s/%s is ignored",
+				managedCluster.GetName(), registry.Namespace, registry.Name)
+			continue
+		}
+		selected = newClusterImageRegistry(registry)
+	}
+	return selected, nil
+}
+
+func newClusterImageRegistry(registry *imageregistryv1alpha1.ManagedClusterImageRegistry) *clusterImageRegistry {
+	imageRegistries := imageregistryv1alpha1.ImageRegistries{Registries: registry.Spec.Registries}
+	// all of the image registries are replaced by the Registry if the Registries is empty
+	if len(imageRegistries.Registries) == 0 && registry.Spec.Registry != "" {
+		imageRegistries.Registries = []imageregistryv1alpha1.Registries{{Mirror: registry.Spec.Registry}}
+	}
+	if registry.Spec.PullSecret.Name != "" {
+		imageRegistries.PullSecret = fmt.Sprintf("%s.%s", registry.Namespace, registry.Spec.PullSecret.Name)
+	}
+	return &clusterImageRegistry{
+		namespace:       registry.Namespace,
+		name:            registry.Name,
+		imageRegistries: imageRegistries,
+	}
+}
+
+// placementClusterNames returns the names of the clusters in the placementDecisions of the placement.
+func placementClusterNames(ctx context.Context, c client.Client, namespace, placement string) (map[string]bool, error) {
+	decisionList := &clusterv1beta1.PlacementDecisionList{}
+	err := c.List(ctx, decisionList, client.InNamespace(namespace),
+		client.MatchingLabels{clusterv1beta1.PlacementLabel: placement})
+	if meta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the placementDecisions of placement %s/%s. err:%v",
+			namespace, placement, err)
+	}
+
+	clusterNames := map[string]bool{}
+	for _, decision := range decisionList.Items {
+		for _, d := range decision.Status.Decisions {
+			clusterNames[d.ClusterName] = true
+		}
+	}
+	return clusterNames, nil
+}
+
+// clusterRequestsHandler returns the handler which requeues the clusters mapped from the object. The clusters mapped
+// from the old object are requeued as well when the object is updated, so the clusters which are no longer selected
+// are reconciled too.
+func clusterRequestsHandler(mapFn handler.MapFunc) handler.EventHandler {
+	addRequests := func(q workqueue.RateLimitingInterface, obj client.Object) {
+		for _, request := range mapFn(obj) {
+			q.Add(request)
+		}
+	}
+	return handler.Funcs{
+		CreateFunc: func(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+			addRequests(q, e.Object)
+		},
+		UpdateFunc: func(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+			addRequests(q, e.ObjectOld)
+			addRequests(q, e.ObjectNew)
+		},
+		DeleteFunc: func(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+			addRequests(q, e.Object)
+		},
+		GenericFunc: func(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+			addRequests(q, e.Object)
+		},
+	}
+}
+
+// placementDecisionRequests requeues the clusters in the placementDecision if it belongs to the placement of a
+// managedClusterImageRegistry.
+func placementDecisionRequests(c client.Client, decision client.Object) []reconcile.Request {
+	placementDecision, ok := decision.(*clusterv1beta1.PlacementDecision)
+	if !ok {
+		return nil
+	}
+	placement := placementDecision.GetLabels()[clusterv1beta1.PlacementLabel]
+	if placement == "" {
+		return nil
+	}
+
+	registryList := &imageregistryv1alpha1.ManagedClusterImageRegistryList{}
+	if err := c.List(context.TODO(), registryList, client.InNamespace(placementDecision.Namespace)); err != nil {
+		klog.Errorf("failed to list managedClusterImageRegistries. err:%v", err)
+		return nil
+	}
+	referenced := false
+	for _, registry := range registryList.Items {
+		if registry.Spec.PlacementRef.Name == placement {
+			referenced = true
+			break
+		}
+	}
+	if !referenced {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, d := range placementDecision.Status.Decisions {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: d.ClusterName, Namespace: d.ClusterName},
+		})
+	}
+	return requests
+}
+
+// imageRegistryRequests requeues the clusters selected by the placement of the managedClusterImageRegistry.
+func imageRegistryRequests(c client.Client, obj client.Object) []reconcile.Request {
+	registry, ok := obj.(*imageregistryv1alpha1.ManagedClusterImageRegistry)
+	if !ok {
+		return nil
+	}
+	clusterNames, err := placementClusterNames(context.TODO(), c, registry.Namespace, registry.Spec.PlacementRef.Name)
+	if err != nil {
+		klog.Errorf("failed to get the clusters of managedClusterImageRegistry %s/%s. err:%v",
+			registry.Namespace, registry.Name, err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for clusterName := range clusterNames {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: clusterName, Namespace: clusterName},
+		})
+	}
+	return requests
+}
@@ -0,0 +1,107 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"reflect"
+	"testing"
+
+	imageregistryv1alpha1 "github.com/stolostron/cluster-lifecycle-api/imageregistry/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
+	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func newPlacementDecision(placement string, clusterNames ...string) *clusterv1beta1.PlacementDecision {
+	decision := &clusterv1beta1.PlacementDecision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      placement + "-decision-1",
+			Namespace: "ns1",
+			Labels:    map[string]string{clusterv1beta1.PlacementLabel: placement},
+		},
+	}
+	for _, clusterName := range clusterNames {
+		decision.Status.Decisions = append(decision.Status.Decisions,
+			clusterv1beta1.ClusterDecision{ClusterName: clusterName})
+	}
+	return decision
+}
+
+func newManagedClusterImageRegistry(placement string) *imageregistryv1alpha1.ManagedClusterImageRegistry {
+	return &imageregistryv1alpha1.ManagedClusterImageRegistry{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry1", Namespace: "ns1"},
+		Spec: imageregistryv1alpha1.ImageRegistrySpec{
+			PlacementRef: imageregistryv1alpha1.PlacementRef{Name: placement},
+		},
+	}
+}
+
+func Test_clusterRequestsHandler(t *testing.T) {
+	tests := []struct {
+		name             string
+		objs             []runtime.Object
+		mapFn            func(c client.Client, obj client.Object) []reconcile.Request
+		oldObj           client.Object
+		newObj           client.Object
+		expectedClusters []string
+	}{
+		{
+			name: "cluster removed from the placementDecision",
+			objs: []runtime.Object{newManagedClusterImageRegistry("placement1")},
+			mapFn: func(c client.Client, obj client.Object) []reconcile.Request {
+				return placementDecisionRequests(c, obj)
+			},
+			oldObj:           newPlacementDecision("placement1", "cluster1", "cluster2"),
+			newObj:           newPlacementDecision("placement1", "cluster2"),
+			expectedClusters: []string{"cluster1", "cluster2"},
+		},
+		{
+			name: "placement of the managedClusterImageRegistry changed",
+			objs: []runtime.Object{
+				newPlacementDecision("placement1", "cluster1"),
+				newPlacementDecision("placement2", "cluster2"),
+			},
+			mapFn: func(c client.Client, obj client.Object) []reconcile.Request {
+				return imageRegistryRequests(c, obj)
+			},
+			oldObj:           newManagedClusterImageRegistry("placement1"),
+			newObj:           newManagedClusterImageRegistry("placement2"),
+			expectedClusters: []string{"cluster1", "cluster2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := runtime.NewScheme()
+			_ = scheme.AddToScheme(s)
+			_ = clusterv1beta1.AddToScheme(s)
+			_ = imageregistryv1alpha1.AddToScheme(s)
+			c := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(tt.objs...).Build()
+
+			h := clusterRequestsHandler(func(obj client.Object) []reconcile.Request { return tt.mapFn(c, obj) })
+			q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+			defer q.ShutDown()
+			h.Update(event.UpdateEvent{ObjectOld: tt.oldObj, ObjectNew: tt.newObj}, q)
+
+			clusters := map[string]bool{}
+			for q.Len() > 0 {
+				item, _ := q.Get()
+				clusters[item.(reconcile.Request).Name] = true
+				q.Done(item)
+			}
+			expected := map[string]bool{}
+			for _, clusterName := range tt.expectedClusters {
+				expected[clusterName] = true
+			}
+			if !reflect.DeepEqual(clusters, expected) {
+				t.Errorf("expected clusters %v are requeued, but got %v", expected, clusters)
+			}
+		})
+	}
+}
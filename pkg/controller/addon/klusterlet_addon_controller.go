@@ -5,15 +5,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 
 	imageregistryv1alpha1 "github.com/stolostron/cluster-lifecycle-api/imageregistry/v1alpha1"
 	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
 	"github.com/stolostron/klusterlet-addon-controller/pkg/common"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	managedclusterv1 "open-cluster-management.io/api/cluster/v1"
@@ -33,6 +39,10 @@ const (
 
 	// annotationValues is the key name of values annotation on managedClusterAddon
 	annotationValues = "addon.open-cluster-management.io/values"
+
+	// annotationValuesManaged is the key name of the annotation on the work-manager managedClusterAddon, which is
+	// set to true if the global values in its values annotation are set by the controller
+	annotationValuesManaged = "agent.open-cluster-management.io/values-managed"
 )
 
 var hostedAddOns = sets.NewString(agentv1.PolicyFrameworkAddonName, agentv1.ConfigPolicyAddonName,
@@ -44,14 +54,35 @@ type globalValues struct {
 }
 
 type global struct {
-	ImageOverrides map[string]string `json:"imageOverrides,omitempty"`
-	NodeSelector   map[string]string `json:"nodeSelector,omitempty"`
-	ProxyConfig    map[string]string `json:"proxyConfig,omitempty"`
+	ImageOverrides map[string]string   `json:"imageOverrides,omitempty"`
+	NodeSelector   map[string]string   `json:"nodeSelector,omitempty"`
+	Tolerations    []corev1.Toleration `json:"tolerations,omitempty"`
+	ProxyConfig    map[string]string   `json:"proxyConfig,omitempty"`
+	ProxyCABundle  string              `json:"proxyCABundle,omitempty"`
+	// ProxyConfigSecret is the name of the secret in the install namespace of the addon, which contains
+	// the proxyConfig with the credentials.
+	ProxyConfigSecret string `json:"proxyConfigSecret,omitempty"`
+	// ImagePullSecret is the name of the image pull secret in the install namespace of the addon.
+	ImagePullSecret string `json:"imagePullSecret,omitempty"`
+}
+
+// managedGlobalValueKeys are the keys of the global values set by the controller.
+var managedGlobalValueKeys = []string{
+	"imageOverrides",
+	"nodeSelector",
+	"tolerations",
+	"proxyConfig",
+	"proxyCABundle",
+	"proxyConfigSecret",
+	"imagePullSecret",
 }
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileKlusterletAddOn{client: mgr.GetClient()}
+	return &ReconcileKlusterletAddOn{
+		client:   mgr.GetClient(),
+		recorder: mgr.GetEventRecorderFor("klusterlet-addon-controller"),
+	}
 }
 
 func klusterletAddonPredicate() predicate.Predicate {
@@ -85,7 +116,8 @@ func klusterletAddonPredicate() predicate.Predicate {
 }
 
 type ReconcileKlusterletAddOn struct {
-	client client.Client
+	client   client.Client
+	recorder record.EventRecorder
 }
 
 func (r *ReconcileKlusterletAddOn) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
@@ -93,13 +125,15 @@ func (r *ReconcileKlusterletAddOn) Reconcile(ctx context.Context, request reconc
 	managedCluster := &managedclusterv1.ManagedCluster{}
 	if err := r.client.Get(ctx, types.NamespacedName{Name: request.Namespace}, managedCluster); err != nil {
 		if errors.IsNotFound(err) {
-			return reconcile.Result{}, r.deleteAllManagedClusterAddon(ctx, request.Name)
+			// the manifestWorks in the namespace of the hosting cluster are not known without the cluster
+			return reconcile.Result{}, r.deleteAllManagedClusterAddon(ctx, request.Name, "")
 		}
 		return reconcile.Result{}, err
 	}
 
 	if !managedCluster.DeletionTimestamp.IsZero() {
-		return reconcile.Result{}, r.deleteAllManagedClusterAddon(ctx, managedCluster.GetName())
+		return reconcile.Result{}, r.deleteAllManagedClusterAddon(ctx, managedCluster.GetName(),
+			getAddOnHostingClusterName(managedCluster))
 	}
 
 	// Fetch the klusterletAddonConfig instance
@@ -115,36 +149,160 @@ func (r *ReconcileKlusterletAddOn) Reconcile(ctx context.Context, request reconc
 		return reconcile.Result{}, nil
 	}
 
-	nodeSelector, err := getNodeSelector(managedCluster)
+	// the addons use the proxy config of the ProxyProfile instead of the inlined ProxyConfig
+	klusterletAddonConfig, customProxyNamespaces, proxyProfileCondition, err := r.applyProxyProfile(ctx,
+		klusterletAddonConfig)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// the addons of the self-managed cluster are placed on the nodes of the MultiClusterHub by default
+	nodeSelector, tolerations, err := r.getLocalClusterNodePlacement(ctx, managedCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	kc, err := r.getKlusterletConfig(ctx, managedCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if klusterletAddonConfig.Spec.NodePlacementPolicy == agentv1.NodePlacementPolicyKlusterlet && kc != nil {
+		nodeSelector, tolerations = kc.nodeSelector, kc.tolerations
+	}
+
+	hubNoProxy, err := r.getHubNoProxy(ctx, klusterletAddonConfig)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	hubMirrors, err := r.getHubImageMirrors(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// the images are rewritten by the managedClusterImageRegistry selecting the cluster instead of the annotation
+	imageRegistry, err := r.getClusterImageRegistry(ctx, managedCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	imageRegistryCondition := imageRegistry.condition(managedCluster)
+	managedCluster, err = imageRegistry.apply(managedCluster)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
 
 	addOnHostingClusterName := getAddOnHostingClusterName(managedCluster)
 	var aggregatedErrs []error
-	for addonName, needUpdate := range agentv1.KlusterletAddons {
+	var addonStatuses []agentv1.KlusterletAddonStatus
+	var archErrs []error
+	var notAllowedErrs []error
+	policy := getImagePolicy()
+	// the addons are reconciled in order, so the errors and the statuses are stable
+	for _, addonName := range sets.StringKeySet(agentv1.KlusterletAddons).List() {
 		if !addonIsEnabled(addonName, klusterletAddonConfig) {
-			if err := r.deleteManagedClusterAddon(ctx, addonName, managedCluster.GetName()); err != nil {
+			if err := r.deleteManagedClusterAddon(ctx, addonName, managedCluster.GetName(),
+				addOnHostingClusterName); err != nil {
+				aggregatedErrs = append(aggregatedErrs, err)
+			}
+			continue
+		}
+
+		// work-manger addon handles by itself, its values are updated here only if it is opted in, and the values
+		// set here are removed when it is opted out.
+		if !addonIsManaged(addonName, klusterletAddonConfig) {
+			if err := r.removeManagedValues(ctx, addonName, managedCluster.GetName(),
+				addOnHostingClusterName); err != nil {
 				aggregatedErrs = append(aggregatedErrs, err)
 			}
 			continue
 		}
 
-		// work-manger addon handles by itself, does not need to update here.
-		if !needUpdate {
+		proxyPolicy := getAddonProxyPolicy(addonName, klusterletAddonConfig, kc)
+		imageOverrides, images, imageErr := getImageOverrides(managedCluster, klusterletAddonConfig, hubMirrors,
+			policy, addonName)
+		addonStatuses = append(addonStatuses, agentv1.KlusterletAddonStatus{
+			Name:        addonName,
+			ProxyPolicy: proxyPolicy,
+			Images:      images,
+			Conditions:  []metav1.Condition{getImageResolvedCondition(imageErr)},
+		})
+		if imageErr != nil {
+			// the addon is not updated to avoid deploying the images which are not expected, like the images of
+			// another architecture. It is reconciled again when the image manifest, the ManagedCluster or the
+			// klusterletAddonConfig is changed.
+			klog.Warningf("failed to resolve the images of addon %s for cluster %s. err:%v", addonName,
+				managedCluster.GetName(), imageErr)
+			archErrs = append(archErrs, imageErr.architectureErrors(addonName)...)
+			if addonNotAllowedErrs := imageErr.notAllowedErrors(addonName); len(addonNotAllowedErrs) != 0 {
+				r.recorder.Eventf(klusterletAddonConfig, corev1.EventTypeWarning, "ImageNotAllowed",
+					"The images of addon %s are not allowed by the image policy. %v", addonName, addonNotAllowedErrs)
+				notAllowedErrs = append(notAllowedErrs, addonNotAllowedErrs...)
+			}
 			continue
 		}
 
-		imageOverrides, err := getImageOverrides(managedCluster, addonName)
+		proxyConfig := getAddonProxyConfig(addonName, proxyPolicy, klusterletAddonConfig, kc, hubNoProxy)
+		// only the custom proxy config may reference the configmap and the secret out of the namespace of the
+		// klusterletAddonConfig by the proxyProfile
+		refNamespaces := proxyNamespaces{
+			trustedCABundle:   klusterletAddonConfig.Namespace,
+			credentialsSecret: klusterletAddonConfig.Namespace,
+		}
+		if proxyPolicy == agentv1.ProxyPolicyCustomProxy {
+			refNamespaces = customProxyNamespaces
+		}
+		proxyCABundle, err := r.getProxyCABundle(ctx, proxyConfig, refNamespaces.trustedCABundle)
 		if err != nil {
-			return reconcile.Result{}, err
+			aggregatedErrs = append(aggregatedErrs, err)
+			continue
+		}
+		if proxyPolicy == agentv1.ProxyPolicyKlusterlet && proxyConfig != nil {
+			proxyCABundle = kc.proxyCABundle
+		}
+		proxyConfigSecret, err := r.applyProxyConfigSecret(ctx, addonName, proxyConfig,
+			refNamespaces.credentialsSecret, managedCluster.GetName(), addOnHostingClusterName)
+		if err != nil {
+			aggregatedErrs = append(aggregatedErrs, err)
+			continue
+		}
+		imagePullSecret, err := r.applyImagePullSecret(ctx, addonName, imageRegistry, managedCluster.GetName(),
+			addOnHostingClusterName)
+		if err != nil {
+			aggregatedErrs = append(aggregatedErrs, err)
+			continue
 		}
-		gv := getGlobalValues(nodeSelector, imageOverrides, addonName, klusterletAddonConfig)
+		gv := getGlobalValues(nodeSelector, tolerations, imageOverrides, proxyCABundle, proxyConfig)
+		gv.Global.ProxyConfigSecret = proxyConfigSecret
+		gv.Global.ImagePullSecret = imagePullSecret
 
 		if err := r.updateManagedClusterAddon(ctx, gv, addonName, managedCluster.GetName(), addOnHostingClusterName); err != nil {
 			aggregatedErrs = append(aggregatedErrs, err)
 		}
 	}
+
+	// the image manifest is not loaded if there is no image manifest for the hub version
+	manifestVersion, _ := agentv1.GetImageManifestVersion()
+	conditions := map[string]*metav1.Condition{
+		agentv1.ManifestVersionPinned:       getManifestVersionPinnedCondition(klusterletAddonConfig),
+		agentv1.ArchitectureImagesAvailable: getArchitectureImagesCondition(managedCluster, archErrs),
+		agentv1.ImagesAllowed:               getImagesAllowedCondition(policy, notAllowedErrs),
+		agentv1.ProxyProfileResolved:        proxyProfileCondition,
+		agentv1.ImageRegistryApplied:        imageRegistryCondition,
+	}
+	if err := r.updateStatus(ctx, klusterletAddonConfig, func(status *agentv1.KlusterletAddonConfigStatus) {
+		status.Addons = mergeAddonStatuses(status.Addons, addonStatuses)
+		status.ImageManifestVersion = manifestVersion
+		status.ImageRegistry = imageRegistry.status()
+		for conditionType, condition := range conditions {
+			if condition == nil {
+				meta.RemoveStatusCondition(&status.Conditions, conditionType)
+				continue
+			}
+			meta.SetStatusCondition(&status.Conditions, *condition)
+		}
+	}); err != nil {
+		aggregatedErrs = append(aggregatedErrs, err)
+	}
 	if len(aggregatedErrs) != 0 {
 		return reconcile.Result{}, fmt.Errorf("failed create/update addon %v", aggregatedErrs)
 	}
@@ -152,10 +310,11 @@ func (r *ReconcileKlusterletAddOn) Reconcile(ctx context.Context, request reconc
 	return reconcile.Result{}, nil
 }
 
-func (r *ReconcileKlusterletAddOn) deleteAllManagedClusterAddon(ctx context.Context, clusterName string) error {
+func (r *ReconcileKlusterletAddOn) deleteAllManagedClusterAddon(ctx context.Context, clusterName,
+	hostingClusterName string) error {
 	var aggregatedErrs []error
 	for addonName := range agentv1.KlusterletAddons {
-		err := r.deleteManagedClusterAddon(ctx, addonName, clusterName)
+		err := r.deleteManagedClusterAddon(ctx, addonName, clusterName, hostingClusterName)
 		if err != nil {
 			aggregatedErrs = append(aggregatedErrs, err)
 		}
@@ -166,7 +325,8 @@ func (r *ReconcileKlusterletAddOn) deleteAllManagedClusterAddon(ctx context.Cont
 	return nil
 }
 
-func (r *ReconcileKlusterletAddOn) deleteManagedClusterAddon(ctx context.Context, addonName, clusterName string) error {
+func (r *ReconcileKlusterletAddOn) deleteManagedClusterAddon(ctx context.Context, addonName, clusterName,
+	hostingClusterName string) error {
 	addon := &addonv1alpha1.ManagedClusterAddOn{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      addonName,
@@ -179,7 +339,45 @@ func (r *ReconcileKlusterletAddOn) deleteManagedClusterAddon(ctx context.Context
 		return err
 	}
 
-	return nil
+	return r.deleteAddonManifestWorks(ctx, addonName, clusterName, hostingClusterName)
+}
+
+// removeManagedValues removes the global values set by the controller from the values annotation of the addon, and
+// deletes the manifestWorks of the secrets referenced by the values, if the addon is marked with the values-managed
+// annotation. The other values of the addon are kept.
+func (r *ReconcileKlusterletAddOn) removeManagedValues(ctx context.Context, addonName, clusterName,
+	hostingClusterName string) error {
+	addon := &addonv1alpha1.ManagedClusterAddOn{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: addonName, Namespace: clusterName}, addon)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, ok := addon.GetAnnotations()[annotationValuesManaged]; !ok {
+		return nil
+	}
+
+	// the annotation is removed at last, so the manifestWorks and the values are removed again if it fails
+	if err := r.deleteAddonManifestWorks(ctx, addonName, clusterName, hostingClusterName); err != nil {
+		return err
+	}
+
+	addon = addon.DeepCopy()
+	delete(addon.Annotations, annotationValuesManaged)
+	if values, ok := addon.Annotations[annotationValues]; ok {
+		valuesString, err := removeGlobalValues(values)
+		if err != nil {
+			return fmt.Errorf("failed to remove the values of addon %s/%s. err:%v", clusterName, addonName, err)
+		}
+		if len(valuesString) != 0 {
+			addon.Annotations[annotationValues] = valuesString
+		} else {
+			delete(addon.Annotations, annotationValues)
+		}
+	}
+	return r.client.Update(ctx, addon)
 }
 
 func (r *ReconcileKlusterletAddOn) updateManagedClusterAddon(ctx context.Context, gv globalValues, addonName, clusterName string, hostingClusterName string) error {
@@ -208,6 +406,22 @@ func (r *ReconcileKlusterletAddOn) updateManagedClusterAddon(ctx context.Context
 	update := false
 	addon = addon.DeepCopy()
 
+	if addonName == agentv1.WorkManagerAddonName {
+		// work-manager handles its values by itself, so only the global values set by the controller are replaced,
+		// and the addon is marked to remove them when its values are not managed anymore
+		valuesString, err = replaceManagedValues(gv, addon.GetAnnotations()[annotationValues])
+		if err != nil {
+			return fmt.Errorf("failed to update the values of addon %s/%s. err:%v", clusterName, addonName, err)
+		}
+		if _, ok := addon.GetAnnotations()[annotationValuesManaged]; !ok {
+			if addon.Annotations == nil {
+				addon.Annotations = map[string]string{}
+			}
+			addon.Annotations[annotationValuesManaged] = "true"
+			update = true
+		}
+	}
+
 	if len(addon.Annotations) == 0 && len(valuesString) != 0 {
 		addon.SetAnnotations(map[string]string{annotationValues: valuesString})
 		update = true
@@ -239,6 +453,47 @@ func (r *ReconcileKlusterletAddOn) updateManagedClusterAddon(ctx context.Context
 	return nil
 }
 
+// updateStatus updates the status of the klusterletAddonConfig with the change if the status is changed.
+func (r *ReconcileKlusterletAddOn) updateStatus(ctx context.Context, config *agentv1.KlusterletAddonConfig,
+	change func(status *agentv1.KlusterletAddonConfigStatus)) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		klusterletAddonConfig := &agentv1.KlusterletAddonConfig{}
+		err := r.client.Get(ctx, types.NamespacedName{Name: config.Name, Namespace: config.Namespace},
+			klusterletAddonConfig)
+		if err != nil {
+			return err
+		}
+
+		newStatus := klusterletAddonConfig.Status.DeepCopy()
+		change(newStatus)
+		if equality.Semantic.DeepEqual(klusterletAddonConfig.Status, *newStatus) {
+			return nil
+		}
+		klusterletAddonConfig.Status = *newStatus
+		return r.client.Status().Update(ctx, klusterletAddonConfig)
+	})
+}
+
+// mergeAddonStatuses returns the addon statuses whose conditions are merged into the conditions of the existing
+// addon statuses, so the lastTransitionTime of a condition is not changed if its status is not changed.
+func mergeAddonStatuses(existing, addonStatuses []agentv1.KlusterletAddonStatus) []agentv1.KlusterletAddonStatus {
+	merged := make([]agentv1.KlusterletAddonStatus, 0, len(addonStatuses))
+	for _, addonStatus := range addonStatuses {
+		var conditions []metav1.Condition
+		for _, existingStatus := range existing {
+			if existingStatus.Name == addonStatus.Name {
+				conditions = append(conditions, existingStatus.Conditions...)
+			}
+		}
+		for _, condition := range addonStatus.Conditions {
+			meta.SetStatusCondition(&conditions, condition)
+		}
+		addonStatus.Conditions = conditions
+		merged = append(merged, addonStatus)
+	}
+	return merged
+}
+
 // isPaused returns true if the KlusterletAddonConfig instance is labeled as paused, and false otherwise
 func isPaused(instance *agentv1.KlusterletAddonConfig) bool {
 	a := instance.GetAnnotations()
@@ -254,99 +509,258 @@ func isPaused(instance *agentv1.KlusterletAddonConfig) bool {
 	return false
 }
 
-func getNodeSelector(managedCluster *managedclusterv1.ManagedCluster) (map[string]string, error) {
-	var nodeSelector map[string]string
-	if managedCluster.GetName() == "local-cluster" {
-		annotations := managedCluster.GetAnnotations()
-		if nodeSelectorString, ok := annotations[annotationNodeSelector]; ok {
-			if err := json.Unmarshal([]byte(nodeSelectorString), &nodeSelector); err != nil {
-				klog.Error(err, "failed to unmarshal nodeSelector annotation of cluster %v", managedCluster.GetName())
-				return nodeSelector, err
+// getImageOverrides returns the images overriding the images in the image manifest for the addon, and the effective
+// images of the addon. The imageOverrides of the addon take precedence over the imageOverrides of the
+// klusterletAddonConfig, which take precedence over the images rewritten by the ClusterImageRegistries annotation.
+// The images are rewritten by the hub mirrors if the ManagedCluster has no ClusterImageRegistries annotation.
+func getImageOverrides(managedCluster *managedclusterv1.ManagedCluster, config *agentv1.KlusterletAddonConfig,
+	hubMirrors *hubImageMirrors, policy *imagePolicy, addonName string) (map[string]string, map[string]string,
+	*imageResolutionError) {
+	imageOverrides := map[string]string{}
+	images := map[string]string{}
+	_, hasImageRegistries := managedCluster.GetAnnotations()[imageregistryv1alpha1.ClusterImageRegistriesAnnotation]
+	if hasImageRegistries {
+		hubMirrors = nil
+	}
+
+	var addonImageOverrides map[string]string
+	if agentConfig := getAddonAgentConfig(addonName, config); agentConfig != nil {
+		addonImageOverrides = agentConfig.ImageOverrides
+	}
+	// the images of the pinned image manifest are different from the images of the hub version
+	manifestVersion := getAddonManifestVersion(addonName, config)
+	pinned := manifestVersion != "" && agentv1.IsImageManifestLoaded(manifestVersion)
+	rewritten := hasImageRegistries || hubMirrors != nil || pinned
+
+	// the images of the image manifest are not checked by the image policy unless they are rewritten
+	var manifestCluster *managedclusterv1.ManagedCluster
+	if policy != nil && (hasImageRegistries || hubMirrors != nil) {
+		manifestCluster = managedCluster.DeepCopy()
+		delete(manifestCluster.Annotations, imageregistryv1alpha1.ClusterImageRegistriesAnnotation)
+	}
+
+	unresolved := map[string]error{}
+
+	for _, imageKey := range agentv1.KlusterletAddonImageNames[addonName] {
+		override, ok := addonImageOverrides[imageKey]
+		if !ok || override == "" {
+			override = config.Spec.ImageOverrides[imageKey]
+		}
+		if override != "" {
+			if err := policy.validate(override); err != nil {
+				unresolved[imageKey] = err
+				continue
+			}
+			imageOverrides[imageKey], images[imageKey] = override, override
+			continue
+		}
+		// the addons use the images of their charts if there are no image manifests in the standalone mode
+		if agentv1.IsStandaloneMode() {
+			continue
+		}
+
+		image, err := agentv1.GetImageOfManifestVersion(managedCluster, imageKey, manifestVersion)
+		if err != nil {
+			if rewritten || agentv1.IsArchitectureImageNotFound(err) {
+				unresolved[imageKey] = err
+				continue
+			}
+			// the image is not required by the addon if it is not rewritten
+			klog.V(4).Infof("failed to get the image %s of addon %s. err:%v", imageKey, addonName, err)
+			continue
+		}
+		if hubMirrors != nil {
+			if image, err = hubMirrors.overrideImage(image); err != nil {
+				unresolved[imageKey] = err
+				continue
+			}
+		}
+		if manifestCluster != nil {
+			manifestImage, err := agentv1.GetImageOfManifestVersion(manifestCluster, imageKey, manifestVersion)
+			if err == nil && manifestImage != image {
+				err = policy.validate(image)
 			}
+			if err != nil {
+				unresolved[imageKey] = err
+				continue
+			}
+		}
+		// the addons use the images of the hub version by default, which are not the images of the architecture
+		if rewritten || agentv1.IsArchitectureImage(managedCluster, imageKey, manifestVersion) {
+			imageOverrides[imageKey] = image
 		}
+		images[imageKey] = image
+	}
+
+	if len(images) == 0 {
+		images = nil
+	}
+	if len(unresolved) != 0 {
+		return imageOverrides, images, &imageResolutionError{errs: unresolved}
 	}
+	return imageOverrides, images, nil
+}
 
-	return nodeSelector, nil
+// imageResolutionError contains the errors of the image keys which are not resolved for an addon.
+type imageResolutionError struct {
+	errs map[string]error
 }
 
-func getImageOverrides(managedCluster *managedclusterv1.ManagedCluster, addonName string) (map[string]string, error) {
-	imageOverrides := map[string]string{}
-	if len(managedCluster.Annotations) == 0 {
-		return imageOverrides, nil
+func (e *imageResolutionError) imageKeys() []string {
+	keys := make([]string, 0, len(e.errs))
+	for key := range e.errs {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+	return keys
+}
 
-	if _, ok := managedCluster.Annotations[imageregistryv1alpha1.ClusterImageRegistriesAnnotation]; !ok {
-		return imageOverrides, nil
+func (e *imageResolutionError) Error() string {
+	var messages []string
+	for _, key := range e.imageKeys() {
+		messages = append(messages, fmt.Sprintf("%s: %v", key, e.errs[key]))
 	}
+	return fmt.Sprintf("failed to resolve the images %v. %s", e.imageKeys(), strings.Join(messages, "; "))
+}
 
-	for _, imageKey := range agentv1.KlusterletAddonImageNames[addonName] {
-		image, err := agentv1.GetImage(managedCluster, imageKey)
-		if err != nil {
-			return imageOverrides, err
+// architectureErrors returns the errors of the image keys which have no image for the architecture of the cluster.
+func (e *imageResolutionError) architectureErrors(addonName string) []error {
+	return e.addonErrors(addonName, agentv1.IsArchitectureImageNotFound)
+}
+
+// notAllowedErrors returns the errors of the image keys whose images are not allowed by the image policy.
+func (e *imageResolutionError) notAllowedErrors(addonName string) []error {
+	return e.addonErrors(addonName, isImageNotAllowed)
+}
+
+func (e *imageResolutionError) addonErrors(addonName string, match func(error) bool) []error {
+	var errs []error
+	for _, key := range e.imageKeys() {
+		if match(e.errs[key]) {
+			errs = append(errs, fmt.Errorf("%s: %v", addonName, e.errs[key]))
 		}
-		imageOverrides[imageKey] = image
 	}
+	return errs
+}
 
-	return imageOverrides, nil
+// getImageResolvedCondition returns the ImageResolved condition of an addon from the image resolution error.
+func getImageResolvedCondition(err *imageResolutionError) metav1.Condition {
+	if err != nil {
+		return metav1.Condition{
+			Type:    agentv1.ImageResolved,
+			Status:  metav1.ConditionFalse,
+			Reason:  agentv1.ReasonImagesNotResolved,
+			Message: err.Error(),
+		}
+	}
+	return metav1.Condition{
+		Type:    agentv1.ImageResolved,
+		Status:  metav1.ConditionTrue,
+		Reason:  agentv1.ReasonImagesResolved,
+		Message: "The images of the addon are resolved",
+	}
 }
 
-func getProxyConfig(addonName string, config *agentv1.KlusterletAddonConfig) map[string]string {
-	var proxyPolicy agentv1.ProxyPolicy
-	switch addonName {
-	case agentv1.ApplicationAddonName:
-		if !config.Spec.ApplicationManagerConfig.Enabled {
-			return nil
+// getAddonManifestVersion returns the image manifest version pinned for the addon. The manifestVersion of the addon
+// takes precedence over the manifestVersion of the klusterletAddonConfig.
+func getAddonManifestVersion(addonName string, config *agentv1.KlusterletAddonConfig) string {
+	if agentConfig := getAddonAgentConfig(addonName, config); agentConfig != nil && agentConfig.ManifestVersion != "" {
+		return agentConfig.ManifestVersion
+	}
+	return config.Spec.ManifestVersion
+}
+
+// getManifestVersionPinnedCondition returns the condition of the image manifest versions pinned for the enabled
+// addons. nil is returned if there is no pinned version.
+func getManifestVersionPinnedCondition(config *agentv1.KlusterletAddonConfig) *metav1.Condition {
+	pinned := sets.NewString()
+	notLoaded := sets.NewString()
+	for addonName := range agentv1.KlusterletAddons {
+		if !addonIsManaged(addonName, config) || !addonIsEnabled(addonName, config) {
+			continue
 		}
-		proxyPolicy = config.Spec.ApplicationManagerConfig.ProxyPolicy
-	case agentv1.CertPolicyAddonName:
-		if !config.Spec.CertPolicyControllerConfig.Enabled {
-			return nil
+		manifestVersion := getAddonManifestVersion(addonName, config)
+		if manifestVersion == "" {
+			continue
 		}
-		proxyPolicy = config.Spec.CertPolicyControllerConfig.ProxyPolicy
-	case agentv1.IamPolicyAddonName:
-		if !config.Spec.IAMPolicyControllerConfig.Enabled {
-			return nil
+		pinned.Insert(manifestVersion)
+		if !agentv1.IsImageManifestLoaded(manifestVersion) {
+			notLoaded.Insert(manifestVersion)
 		}
-		proxyPolicy = config.Spec.IAMPolicyControllerConfig.ProxyPolicy
-	case agentv1.ConfigPolicyAddonName, agentv1.PolicyFrameworkAddonName:
-		if !config.Spec.PolicyController.Enabled {
-			return nil
+	}
+
+	switch {
+	case pinned.Len() == 0:
+		return nil
+	case agentv1.IsStandaloneMode():
+		return &metav1.Condition{
+			Type:   agentv1.ManifestVersionPinned,
+			Status: metav1.ConditionFalse,
+			Reason: agentv1.ReasonPinnedManifestVersionNotLoaded,
+			Message: fmt.Sprintf("The image manifests are not used in the standalone mode, "+
+				"the image manifest versions %v are ignored", pinned.List()),
 		}
-		proxyPolicy = config.Spec.PolicyController.ProxyPolicy
-	case agentv1.SearchAddonName:
-		if !config.Spec.SearchCollectorConfig.Enabled {
-			return nil
+	case notLoaded.Len() != 0:
+		return &metav1.Condition{
+			Type:   agentv1.ManifestVersionPinned,
+			Status: metav1.ConditionFalse,
+			Reason: agentv1.ReasonPinnedManifestVersionNotLoaded,
+			Message: fmt.Sprintf("The image manifest versions %v are not loaded, "+
+				"the image manifest of the hub version is used instead", notLoaded.List()),
+		}
+	default:
+		return &metav1.Condition{
+			Type:    agentv1.ManifestVersionPinned,
+			Status:  metav1.ConditionTrue,
+			Reason:  agentv1.ReasonPinnedManifestVersionLoaded,
+			Message: fmt.Sprintf("The image manifest versions %v are used", pinned.List()),
 		}
-		proxyPolicy = config.Spec.SearchCollectorConfig.ProxyPolicy
 	}
+}
 
-	var proxyConfig map[string]string
-	switch proxyPolicy {
-	case agentv1.ProxyPolicyOCPGlobalProxy:
-		proxyConfig = map[string]string{
-			agentv1.HTTPProxy:  config.Status.OCPGlobalProxy.HTTPProxy,
-			agentv1.HTTPSProxy: config.Status.OCPGlobalProxy.HTTPSProxy,
-			agentv1.NoProxy:    config.Status.OCPGlobalProxy.NoProxy,
-		}
-	case agentv1.ProxyPolicyCustomProxy:
-		proxyConfig = map[string]string{
-			agentv1.HTTPProxy:  config.Spec.ProxyConfig.HTTPProxy,
-			agentv1.HTTPSProxy: config.Spec.ProxyConfig.HTTPSProxy,
-			agentv1.NoProxy:    config.Spec.ProxyConfig.NoProxy,
+// getArchitectureImagesCondition returns the condition of the images for the architecture of the ManagedCluster
+// from the errors of the addons whose images are not found for the architecture. nil is returned if the
+// architecture is not reported.
+func getArchitectureImagesCondition(managedCluster *managedclusterv1.ManagedCluster,
+	archErrs []error) *metav1.Condition {
+	arch := agentv1.ManagedClusterArchitecture(managedCluster)
+	if arch == "" {
+		return nil
+	}
+	if len(archErrs) == 0 {
+		return &metav1.Condition{
+			Type:    agentv1.ArchitectureImagesAvailable,
+			Status:  metav1.ConditionTrue,
+			Reason:  agentv1.ReasonArchitectureImagesFound,
+			Message: fmt.Sprintf("The images of the addons are found for architecture %s", arch),
 		}
 	}
-	return proxyConfig
+
+	messages := make([]string, 0, len(archErrs))
+	for _, err := range archErrs {
+		messages = append(messages, err.Error())
+	}
+	sort.Strings(messages)
+	return &metav1.Condition{
+		Type:    agentv1.ArchitectureImagesAvailable,
+		Status:  metav1.ConditionFalse,
+		Reason:  agentv1.ReasonArchitectureImagesNotFound,
+		Message: strings.Join(messages, "; "),
+	}
 }
 
 func getGlobalValues(nodeSelector map[string]string,
+	tolerations []corev1.Toleration,
 	imageOverrides map[string]string,
-	addonName string,
-	config *agentv1.KlusterletAddonConfig) globalValues {
+	proxyCABundle string,
+	proxyConfig *agentv1.ProxyConfig) globalValues {
 	return globalValues{
 		Global: global{
 			ImageOverrides: imageOverrides,
 			NodeSelector:   nodeSelector,
-			ProxyConfig:    getProxyConfig(addonName, config),
+			Tolerations:    tolerations,
+			ProxyConfig:    getProxyConfig(proxyConfig),
+			ProxyCABundle:  proxyCABundle,
 		},
 	}
 }
@@ -381,7 +795,7 @@ func newManagedClusterAddon(addonName, namespace string, hostingClusterName stri
 		addOn.Annotations = map[string]string{
 			common.AnnotationAddOnHostingClusterName: hostingClusterName,
 		}
-		addOn.Spec.InstallNamespace = fmt.Sprintf("klusterlet-%s", namespace)
+		_, addOn.Spec.InstallNamespace = getAddonNamespaces(addonName, namespace, hostingClusterName)
 	}
 
 	return addOn
@@ -389,8 +803,12 @@ func newManagedClusterAddon(addonName, namespace string, hostingClusterName stri
 
 func marshalGlobalValues(values globalValues) (string, error) {
 	if len(values.Global.NodeSelector) == 0 &&
+		len(values.Global.Tolerations) == 0 &&
 		len(values.Global.ProxyConfig) == 0 &&
-		len(values.Global.ImageOverrides) == 0 {
+		len(values.Global.ImageOverrides) == 0 &&
+		len(values.Global.ProxyCABundle) == 0 &&
+		len(values.Global.ProxyConfigSecret) == 0 &&
+		len(values.Global.ImagePullSecret) == 0 {
 		return "", nil
 	}
 
@@ -401,6 +819,57 @@ func marshalGlobalValues(values globalValues) (string, error) {
 	return string(gvRaw), nil
 }
 
+// removeGlobalValues removes the keys of the global values from the values annotation. An empty string is returned
+// if there are no values left.
+func removeGlobalValues(annotationValues string) (string, error) {
+	values := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(annotationValues), &values); err != nil {
+		return "", err
+	}
+	gv, ok := values["global"].(map[string]interface{})
+	if !ok {
+		return annotationValues, nil
+	}
+
+	for _, key := range managedGlobalValueKeys {
+		delete(gv, key)
+	}
+	if len(gv) == 0 {
+		delete(values, "global")
+	}
+	if len(values) == 0 {
+		return "", nil
+	}
+
+	v, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return string(v), nil
+}
+
+// replaceManagedValues returns the values annotation whose global values set by the controller are replaced by the
+// global values, the other values are kept. An empty string is returned if there are no values.
+func replaceManagedValues(gv globalValues, annotationValues string) (string, error) {
+	if len(annotationValues) != 0 {
+		values, err := removeGlobalValues(annotationValues)
+		if err != nil {
+			return "", fmt.Errorf("failed to unmarshal annotation values. err:%v", err)
+		}
+		annotationValues = values
+	}
+
+	values, err := updateAnnotationValues(gv, annotationValues)
+	if err != nil {
+		return "", err
+	}
+	if len(values) == 0 {
+		// the values are not changed by the global values
+		return annotationValues, nil
+	}
+	return values, nil
+}
+
 func updateAnnotationValues(gv globalValues, annotationValues string) (string, error) {
 	gvStr, err := marshalGlobalValues(gv)
 	if err != nil {
@@ -475,6 +944,15 @@ func mergeValues(a, b map[string]interface{}) map[string]interface{} {
 	return out
 }
 
+// addonIsManaged returns true if the values of the addon are managed by the controller. The values of work-manager
+// are managed only if manageValues is set in the workManager config of the klusterletAddonConfig.
+func addonIsManaged(addonName string, config *agentv1.KlusterletAddonConfig) bool {
+	if addonName == agentv1.WorkManagerAddonName {
+		return config.Spec.WorkManagerConfig != nil && config.Spec.WorkManagerConfig.ManageValues
+	}
+	return agentv1.KlusterletAddons[addonName]
+}
+
 func addonIsEnabled(addonName string, config *agentv1.KlusterletAddonConfig) bool {
 	switch addonName {
 	case agentv1.ApplicationAddonName:
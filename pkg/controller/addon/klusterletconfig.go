@@ -0,0 +1,106 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	managedclusterv1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// annotationKlusterletConfig is the annotation key of the ManagedCluster, which is the name of the KlusterletConfig
+// applied to the klusterlet of the ManagedCluster.
+const annotationKlusterletConfig = "agent.open-cluster-management.io/klusterlet-config"
+
+// defaultKlusterletConfigName is the name of the KlusterletConfig applied to the ManagedClusters without the
+// klusterlet-config annotation.
+const defaultKlusterletConfigName = "global"
+
+var klusterletConfigGVK = schema.GroupVersionKind{
+	Group:   "config.open-cluster-management.io",
+	Version: "v1alpha1",
+	Kind:    "KlusterletConfig",
+}
+
+// klusterletConfig contains the settings of the KlusterletConfig which are inherited by the addons.
+type klusterletConfig struct {
+	// proxyConfig is the proxy config used by the klusterlet to access the hub.
+	proxyConfig agentv1.ProxyConfig
+	// proxyCABundle is the CA bundle of the proxy used by the klusterlet to access the hub.
+	proxyCABundle string
+
+	nodeSelector map[string]string
+	tolerations  []corev1.Toleration
+}
+
+// nodePlacement is the nodePlacement of the KlusterletConfig.
+type nodePlacement struct {
+	NodeSelector map[string]string   `json:"nodeSelector,omitempty"`
+	Tolerations  []corev1.Toleration `json:"tolerations,omitempty"`
+}
+
+// klusterletConfigName returns the name of the KlusterletConfig which applies to the ManagedCluster, it is the
+// klusterlet-config annotation of the ManagedCluster, or the default KlusterletConfig if the annotation is absent.
+func klusterletConfigName(managedCluster client.Object) string {
+	if name := managedCluster.GetAnnotations()[annotationKlusterletConfig]; name != "" {
+		return name
+	}
+	return defaultKlusterletConfigName
+}
+
+// getKlusterletConfig returns the settings of the KlusterletConfig which applies to the ManagedCluster.
+// nil is returned if there is no KlusterletConfig for the ManagedCluster.
+func (r *ReconcileKlusterletAddOn) getKlusterletConfig(ctx context.Context,
+	managedCluster *managedclusterv1.ManagedCluster) (*klusterletConfig, error) {
+	name := klusterletConfigName(managedCluster)
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(klusterletConfigGVK)
+	err := r.client.Get(ctx, types.NamespacedName{Name: name}, obj)
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the klusterletConfig %s. err:%v", name, err)
+	}
+
+	config := &klusterletConfig{}
+	proxyConfig, _, err := unstructured.NestedStringMap(obj.Object, "spec", "hubKubeAPIServerProxyConfig")
+	if err != nil {
+		return nil, fmt.Errorf("invalid hubKubeAPIServerProxyConfig in the klusterletConfig %s. err:%v", name, err)
+	}
+	config.proxyConfig.HTTPProxy = proxyConfig["httpProxy"]
+	config.proxyConfig.HTTPSProxy = proxyConfig["httpsProxy"]
+	if caBundle := proxyConfig["caBundle"]; caBundle != "" {
+		decoded, err := base64.StdEncoding.DecodeString(caBundle)
+		if err != nil {
+			return nil, fmt.Errorf("invalid caBundle in the klusterletConfig %s. err:%v", name, err)
+		}
+		config.proxyCABundle = string(decoded)
+	}
+
+	placement, _, err := unstructured.NestedMap(obj.Object, "spec", "nodePlacement")
+	if err != nil {
+		return nil, fmt.Errorf("invalid nodePlacement in the klusterletConfig %s. err:%v", name, err)
+	}
+	if placement != nil {
+		np := &nodePlacement{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(placement, np); err != nil {
+			return nil, fmt.Errorf("invalid nodePlacement in the klusterletConfig %s. err:%v", name, err)
+		}
+		config.nodeSelector = np.NodeSelector
+		config.tolerations = np.Tolerations
+	}
+	return config, nil
+}
@@ -0,0 +1,140 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	managedclusterv1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// localClusterLabel is the label of the self-managed cluster, the cluster may be renamed from local-cluster.
+	localClusterLabel = "local-cluster"
+
+	// localClusterName is the default name of the self-managed cluster.
+	localClusterName = "local-cluster"
+)
+
+// isLocalCluster returns true if the ManagedCluster is the self-managed cluster. The local-cluster label is respected
+// if it is set, and the name is checked only if there is no label.
+func isLocalCluster(managedCluster *managedclusterv1.ManagedCluster) bool {
+	if value, ok := managedCluster.GetLabels()[localClusterLabel]; ok {
+		return strings.EqualFold(value, "true")
+	}
+	return managedCluster.GetName() == localClusterName
+}
+
+// getLocalClusterNodePlacement returns the nodeSelector and the tolerations of the addons of the self-managed
+// cluster, which are the nodeSelector and the tolerations of the MultiClusterHub. The nodeSelector annotation of the
+// cluster is used if there is no MultiClusterHub on the hub or the controller runs in the standalone mode.
+// Nothing is returned for the other clusters.
+func (r *ReconcileKlusterletAddOn) getLocalClusterNodePlacement(ctx context.Context,
+	managedCluster *managedclusterv1.ManagedCluster) (map[string]string, []corev1.Toleration, error) {
+	if !isLocalCluster(managedCluster) {
+		return nil, nil, nil
+	}
+
+	if !agentv1.IsStandaloneMode() {
+		mch, err := r.getMultiClusterHub(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if mch != nil {
+			return getMultiClusterHubNodePlacement(mch)
+		}
+	}
+
+	nodeSelector, err := getNodeSelector(managedCluster)
+	return nodeSelector, nil, err
+}
+
+// getMultiClusterHub returns the first MultiClusterHub ordered by the namespace and the name. nil is returned if
+// there is no MultiClusterHub or its CRD is not installed on the hub.
+func (r *ReconcileKlusterletAddOn) getMultiClusterHub(ctx context.Context) (*unstructured.Unstructured, error) {
+	mchList := &unstructured.UnstructuredList{}
+	mchList.SetGroupVersionKind(multiClusterHubGVK.GroupVersion().WithKind(multiClusterHubGVK.Kind + "List"))
+	err := r.client.List(ctx, mchList)
+	if meta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list multiClusterHubs. err:%v", err)
+	}
+	if len(mchList.Items) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(mchList.Items, func(i, j int) bool {
+		if mchList.Items[i].GetNamespace() != mchList.Items[j].GetNamespace() {
+			return mchList.Items[i].GetNamespace() < mchList.Items[j].GetNamespace()
+		}
+		return mchList.Items[i].GetName() < mchList.Items[j].GetName()
+	})
+	return &mchList.Items[0], nil
+}
+
+// getMultiClusterHubNodePlacement returns the nodeSelector and the tolerations in the spec of the MultiClusterHub.
+func getMultiClusterHubNodePlacement(mch *unstructured.Unstructured) (map[string]string, []corev1.Toleration,
+	error) {
+	spec, _, err := unstructured.NestedMap(mch.Object, "spec")
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid spec in the multiClusterHub %s/%s. err:%v",
+			mch.GetNamespace(), mch.GetName(), err)
+	}
+
+	np := &nodePlacement{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, np); err != nil {
+		return nil, nil, fmt.Errorf("invalid nodeSelector or tolerations in the multiClusterHub %s/%s. err:%v",
+			mch.GetNamespace(), mch.GetName(), err)
+	}
+	return np.NodeSelector, np.Tolerations, nil
+}
+
+// getNodeSelector returns the nodeSelector in the annotation of the self-managed cluster, which is synced from the
+// MultiClusterHub.
+func getNodeSelector(managedCluster *managedclusterv1.ManagedCluster) (map[string]string, error) {
+	var nodeSelector map[string]string
+	nodeSelectorString, ok := managedCluster.GetAnnotations()[annotationNodeSelector]
+	if !ok {
+		return nodeSelector, nil
+	}
+	if err := json.Unmarshal([]byte(nodeSelectorString), &nodeSelector); err != nil {
+		klog.Errorf("failed to unmarshal nodeSelector annotation of cluster %v. err:%v", managedCluster.GetName(), err)
+		return nodeSelector, err
+	}
+	return nodeSelector, nil
+}
+
+// localClusterRequests returns the requests of the self-managed clusters.
+func localClusterRequests(c client.Client) []reconcile.Request {
+	clusterList := &managedclusterv1.ManagedClusterList{}
+	if err := c.List(context.TODO(), clusterList); err != nil {
+		klog.Errorf("failed to list managedClusters. err:%v", err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range clusterList.Items {
+		if !isLocalCluster(&clusterList.Items[i]) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: clusterList.Items[i].Name, Namespace: clusterList.Items[i].Name},
+		})
+	}
+	return requests
+}
@@ -0,0 +1,148 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	mcv1 "open-cluster-management.io/api/cluster/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newMultiClusterHubWithNodePlacement(namespace, name string, nodeSelector map[string]string,
+	tolerations []corev1.Toleration) *unstructured.Unstructured {
+	mch := newMultiClusterHub("")
+	mch.SetNamespace(namespace)
+	mch.SetName(name)
+	spec, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(&nodePlacement{
+		NodeSelector: nodeSelector,
+		Tolerations:  tolerations,
+	})
+	_ = unstructured.SetNestedMap(mch.Object, spec, "spec")
+	return mch
+}
+
+func Test_getLocalClusterNodePlacement(t *testing.T) {
+	infraTolerations := []corev1.Toleration{
+		{Key: "node-role.kubernetes.io/infra", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	}
+
+	tests := []struct {
+		name                 string
+		managedCluster       *mcv1.ManagedCluster
+		mchs                 []*unstructured.Unstructured
+		standalone           bool
+		expectedNodeSelector map[string]string
+		expectedTolerations  []corev1.Toleration
+	}{
+		{
+			name:           "not the self-managed cluster",
+			managedCluster: newManagedCluster("cluster1", map[string]string{annotationNodeSelector: `{"node":"infra"}`}),
+			mchs: []*unstructured.Unstructured{
+				newMultiClusterHubWithNodePlacement("open-cluster-management", "multiclusterhub",
+					map[string]string{"node": "infra"}, infraTolerations),
+			},
+		},
+		{
+			name:                 "local-cluster without multiClusterHub",
+			managedCluster:       newManagedCluster("local-cluster", map[string]string{annotationNodeSelector: `{"node":"infra"}`}),
+			expectedNodeSelector: map[string]string{"node": "infra"},
+		},
+		{
+			name: "renamed self-managed cluster with multiClusterHub",
+			managedCluster: &mcv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "hub",
+					Labels: map[string]string{localClusterLabel: "true"},
+				},
+			},
+			mchs: []*unstructured.Unstructured{
+				newMultiClusterHubWithNodePlacement("open-cluster-management", "multiclusterhub",
+					map[string]string{"node": "infra"}, infraTolerations),
+			},
+			expectedNodeSelector: map[string]string{"node": "infra"},
+			expectedTolerations:  infraTolerations,
+		},
+		{
+			name: "local-cluster labeled as not the self-managed cluster",
+			managedCluster: &mcv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "local-cluster",
+					Labels:      map[string]string{localClusterLabel: "false"},
+					Annotations: map[string]string{annotationNodeSelector: `{"node":"infra"}`},
+				},
+			},
+			mchs: []*unstructured.Unstructured{
+				newMultiClusterHubWithNodePlacement("open-cluster-management", "multiclusterhub",
+					map[string]string{"node": "infra"}, infraTolerations),
+			},
+		},
+		{
+			name: "multiClusterHub takes precedence over the annotation",
+			managedCluster: newManagedCluster("local-cluster", map[string]string{
+				annotationNodeSelector: `{"node":"old"}`,
+			}),
+			mchs: []*unstructured.Unstructured{
+				newMultiClusterHubWithNodePlacement("open-cluster-management", "multiclusterhub",
+					map[string]string{"node": "infra"}, nil),
+			},
+			expectedNodeSelector: map[string]string{"node": "infra"},
+		},
+		{
+			name: "the first multiClusterHub is used",
+			managedCluster: &mcv1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "hub",
+					Labels: map[string]string{localClusterLabel: "true"},
+				},
+			},
+			mchs: []*unstructured.Unstructured{
+				newMultiClusterHubWithNodePlacement("ocm", "multiclusterhub", map[string]string{"node": "second"}, nil),
+				newMultiClusterHubWithNodePlacement("acm", "multiclusterhub", map[string]string{"node": "first"}, nil),
+			},
+			expectedNodeSelector: map[string]string{"node": "first"},
+		},
+		{
+			name: "standalone mode",
+			managedCluster: newManagedCluster("local-cluster", map[string]string{
+				annotationNodeSelector: `{"node":"old"}`,
+			}),
+			mchs: []*unstructured.Unstructured{
+				newMultiClusterHubWithNodePlacement("open-cluster-management", "multiclusterhub",
+					map[string]string{"node": "infra"}, infraTolerations),
+			},
+			standalone:           true,
+			expectedNodeSelector: map[string]string{"node": "old"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setStandaloneMode(t, tt.standalone)
+
+			builder := fake.NewClientBuilder().WithScheme(scheme.Scheme)
+			for _, mch := range tt.mchs {
+				builder = builder.WithObjects(mch)
+			}
+			r := &ReconcileKlusterletAddOn{client: builder.Build()}
+
+			nodeSelector, tolerations, err := r.getLocalClusterNodePlacement(context.TODO(), tt.managedCluster)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(nodeSelector, tt.expectedNodeSelector) {
+				t.Errorf("expected nodeSelector %v, but got %v", tt.expectedNodeSelector, nodeSelector)
+			}
+			if !reflect.DeepEqual(tolerations, tt.expectedTolerations) {
+				t.Errorf("expected tolerations %v, but got %v", tt.expectedTolerations, tolerations)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	workv1 "open-cluster-management.io/api/work/v1"
+)
+
+const (
+	// workLabelCluster is the label key of the managed cluster name on the manifestWorks created by the controller
+	workLabelCluster = "agent.open-cluster-management.io/managed-cluster"
+
+	// workLabelAddon is the label key of the addon name on the manifestWorks created by the controller
+	workLabelAddon = "agent.open-cluster-management.io/addon"
+)
+
+// getAddonNamespaces returns the namespace of the manifestWork and the install namespace of the addon.
+// The manifestWork is created in the namespace of the hosting cluster if the addon is deployed in hosted mode.
+func getAddonNamespaces(addonName, clusterName, hostingClusterName string) (string, string) {
+	if hostedAddOns.Has(addonName) && len(hostingClusterName) > 0 {
+		return hostingClusterName, fmt.Sprintf("klusterlet-%s", clusterName)
+	}
+	return clusterName, agentv1.KlusterletAddonNamespace
+}
+
+// newSecretManifestWork returns a manifestWork which deploys the secret for the addon of the managed cluster.
+func newSecretManifestWork(name, namespace, clusterName, addonName string,
+	secret *corev1.Secret) (*workv1.ManifestWork, error) {
+	secret = secret.DeepCopy()
+	secret.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"}
+	raw, err := json.Marshal(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &workv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				workLabelCluster: clusterName,
+				workLabelAddon:   addonName,
+			},
+		},
+		Spec: workv1.ManifestWorkSpec{
+			Workload: workv1.ManifestsTemplate{
+				Manifests: []workv1.Manifest{
+					{RawExtension: runtime.RawExtension{Raw: raw}},
+				},
+			},
+		},
+	}, nil
+}
+
+// applyManifestWork creates the manifestWork or updates it if the workload is changed.
+func (r *ReconcileKlusterletAddOn) applyManifestWork(ctx context.Context, work *workv1.ManifestWork) error {
+	existing := &workv1.ManifestWork{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: work.Name, Namespace: work.Namespace}, existing)
+	if errors.IsNotFound(err) {
+		return r.client.Create(ctx, work)
+	}
+	if err != nil {
+		return err
+	}
+
+	if equality.Semantic.DeepEqual(existing.Spec, work.Spec) &&
+		equality.Semantic.DeepEqual(existing.Labels, work.Labels) {
+		return nil
+	}
+
+	existing = existing.DeepCopy()
+	existing.Labels = work.Labels
+	existing.Spec = work.Spec
+	return r.client.Update(ctx, existing)
+}
+
+// addonWorkName returns the name of the manifestWork which deploys the secret with the name suffix for the addon of
+// the managed cluster.
+func addonWorkName(clusterName, addonName, suffix string) string {
+	return fmt.Sprintf("%s-%s-%s", clusterName, addonName, suffix)
+}
+
+// deleteManifestWork deletes the manifestWork in the namespaces of the managed cluster and the hosting cluster,
+// except the one in the keepNamespace. The manifestWork is deleted by name, so the manifestWorks on the hub are not
+// listed or cached.
+func (r *ReconcileKlusterletAddOn) deleteManifestWork(ctx context.Context, name, clusterName, hostingClusterName,
+	keepNamespace string) error {
+	for _, namespace := range sets.NewString(clusterName, hostingClusterName).List() {
+		if namespace == "" || namespace == keepNamespace {
+			continue
+		}
+		work := &workv1.ManifestWork{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+		if err := r.client.Delete(ctx, work); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteAddonManifestWorks deletes all of the manifestWorks created for the addon of the managed cluster.
+func (r *ReconcileKlusterletAddOn) deleteAddonManifestWorks(ctx context.Context, addonName, clusterName,
+	hostingClusterName string) error {
+	for _, suffix := range []string{proxyConfigSecretSuffix, imagePullSecretSuffix} {
+		err := r.deleteManifestWork(ctx, addonWorkName(clusterName, addonName, suffix), clusterName,
+			hostingClusterName, "")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,353 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	ocinfrav1 "github.com/openshift/api/config/v1"
+	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
+	"github.com/stolostron/klusterlet-addon-controller/pkg/helpers"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// proxyConfigSecretSuffix is the name suffix of the secret which contains the proxyConfig with the credentials
+	proxyConfigSecretSuffix = "proxy-config"
+
+	// annotationDisableHubNoProxy is the annotation key of the klusterletAddonConfig to stop adding the hub
+	// API server and the hub endpoints into the noProxy of the addons.
+	annotationDisableHubNoProxy = "klusterletaddonconfig-disable-hub-noproxy"
+
+	// hubNoProxyEndpointsEnv is the env name of the comma-separated hub endpoints (hosts or URLs), like the
+	// search API route, which are added into the noProxy of the addons together with the hub API server.
+	hubNoProxyEndpointsEnv = "HUB_NO_PROXY_ENDPOINTS"
+
+	// infrastructureName is the name of the cluster-scoped OCP Infrastructure
+	infrastructureName = "cluster"
+)
+
+// getHubNoProxy returns the hosts of the hub API server and the hub endpoints, which should not be accessed
+// through the proxy by the addons. nil is returned if it is disabled by the annotation of the klusterletAddonConfig.
+func (r *ReconcileKlusterletAddOn) getHubNoProxy(ctx context.Context,
+	config *agentv1.KlusterletAddonConfig) ([]string, error) {
+	if strings.EqualFold(config.GetAnnotations()[annotationDisableHubNoProxy], "true") {
+		return nil, nil
+	}
+
+	var endpoints []string
+	infrastructure := &ocinfrav1.Infrastructure{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: infrastructureName}, infrastructure)
+	switch {
+	case err == nil:
+		endpoints = append(endpoints, infrastructure.Status.APIServerURL, infrastructure.Status.APIServerInternalURL)
+	case errors.IsNotFound(err) || meta.IsNoMatchError(err):
+		// the hub is not an OCP cluster, only the configured hub endpoints are added
+	default:
+		return nil, fmt.Errorf("failed to get the infrastructure of the hub. err:%v", err)
+	}
+	endpoints = append(endpoints, strings.Split(os.Getenv(hubNoProxyEndpointsEnv), ",")...)
+
+	var hosts []string
+	for _, endpoint := range endpoints {
+		if host := endpointHost(endpoint); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts, nil
+}
+
+// endpointHost returns the host without the scheme, port and path of the endpoint, which can be a URL or a host.
+func endpointHost(endpoint string) string {
+	endpoint = strings.TrimSpace(endpoint)
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return ""
+		}
+		return u.Hostname()
+	}
+	if host, _, err := net.SplitHostPort(endpoint); err == nil {
+		return host
+	}
+	return endpoint
+}
+
+// mergeNoProxy appends the hosts which are not in the comma-separated noProxy to the noProxy.
+// The noProxy is not changed if it contains "*".
+func mergeNoProxy(noProxy string, hosts []string) string {
+	var entries []string
+	existing := map[string]bool{}
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return noProxy
+		}
+		entries = append(entries, entry)
+		existing[entry] = true
+	}
+
+	added := false
+	for _, host := range hosts {
+		host = strings.TrimSpace(host)
+		if host == "" || existing[host] {
+			continue
+		}
+		entries = append(entries, host)
+		existing[host] = true
+		added = true
+	}
+	if !added {
+		return noProxy
+	}
+	return strings.Join(entries, ",")
+}
+
+// inClusterNoProxy is the noProxy entries of the in-cluster destinations, they are the same with the default entries
+// of the status.noProxy in proxy.config.openshift.io/cluster.
+var inClusterNoProxy = []string{".cluster.local", ".svc", "localhost", "127.0.0.1", "::1"}
+
+// getInClusterNoProxy returns the in-cluster destinations of the managed cluster, including the service and pod
+// networks in the install config if they are known.
+func getInClusterNoProxy(config *agentv1.KlusterletAddonConfig) []string {
+	noProxy := append([]string{}, inClusterNoProxy...)
+	if installConfig := config.Status.InstallConfig; installConfig != nil {
+		noProxy = append(noProxy, installConfig.ServiceNetwork...)
+		noProxy = append(noProxy, installConfig.ClusterNetwork...)
+	}
+	return noProxy
+}
+
+// getAddonAgentConfig returns the agent config of the addon in the klusterletAddonConfig.
+// nil is returned if the addon has no agent config.
+func getAddonAgentConfig(addonName string,
+	config *agentv1.KlusterletAddonConfig) *agentv1.KlusterletAddonAgentConfigSpec {
+	switch addonName {
+	case agentv1.ApplicationAddonName:
+		return &config.Spec.ApplicationManagerConfig
+	case agentv1.CertPolicyAddonName:
+		return &config.Spec.CertPolicyControllerConfig
+	case agentv1.IamPolicyAddonName:
+		return &config.Spec.IAMPolicyControllerConfig
+	case agentv1.ConfigPolicyAddonName, agentv1.PolicyFrameworkAddonName:
+		return &config.Spec.PolicyController
+	case agentv1.SearchAddonName:
+		return &config.Spec.SearchCollectorConfig
+	case agentv1.WorkManagerAddonName:
+		return workManagerAgentConfig(config.Spec.WorkManagerConfig)
+	}
+	return nil
+}
+
+// workManagerAgentConfig returns the agent config of work-manager, it is enabled only if the values of work-manager
+// are managed by the controller.
+func workManagerAgentConfig(workManagerConfig *agentv1.WorkManagerConfigSpec) *agentv1.KlusterletAddonAgentConfigSpec {
+	if workManagerConfig == nil {
+		return nil
+	}
+	return &agentv1.KlusterletAddonAgentConfigSpec{
+		Enabled:           workManagerConfig.ManageValues,
+		ProxyPolicy:       workManagerConfig.ProxyPolicy,
+		AdditionalNoProxy: workManagerConfig.AdditionalNoProxy,
+		ImageOverrides:    workManagerConfig.ImageOverrides,
+		ManifestVersion:   workManagerConfig.ManifestVersion,
+	}
+}
+
+// getAddonProxyPolicy returns the proxy policy applied to the addon, the Auto policy is resolved to
+// CustomProxy, OCPGlobalProxy or Disabled, and the KlusterletProxy policy is resolved to Disabled if there is no
+// hub proxy config in the KlusterletConfig. An empty policy is returned if the addon is disabled.
+func getAddonProxyPolicy(addonName string, config *agentv1.KlusterletAddonConfig,
+	kc *klusterletConfig) agentv1.ProxyPolicy {
+	agentConfig := getAddonAgentConfig(addonName, config)
+	if agentConfig == nil || !agentConfig.Enabled {
+		return ""
+	}
+
+	switch agentConfig.ProxyPolicy {
+	case agentv1.ProxyPolicyOCPGlobalProxy, agentv1.ProxyPolicyCustomProxy:
+		return agentConfig.ProxyPolicy
+	case agentv1.ProxyPolicyKlusterlet:
+		if kc != nil && (kc.proxyConfig.HTTPProxy != "" || kc.proxyConfig.HTTPSProxy != "") {
+			return agentv1.ProxyPolicyKlusterlet
+		}
+	case agentv1.ProxyPolicyAuto:
+		if config.Spec.ProxyConfig.HTTPProxy != "" || config.Spec.ProxyConfig.HTTPSProxy != "" {
+			return agentv1.ProxyPolicyCustomProxy
+		}
+		if meta.IsStatusConditionTrue(config.Status.Conditions, agentv1.OCPGlobalProxyDetected) {
+			return agentv1.ProxyPolicyOCPGlobalProxy
+		}
+	}
+	return agentv1.ProxyPolicyDisable
+}
+
+// getAddonProxyConfig returns the effective proxy config of the proxy policy, which is a copy of the selected
+// proxy config with the hub hosts and the additional noProxy of the addon merged into the noProxy.
+// nil is returned if the proxy is disabled.
+func getAddonProxyConfig(addonName string, proxyPolicy agentv1.ProxyPolicy, config *agentv1.KlusterletAddonConfig,
+	kc *klusterletConfig, hubNoProxy []string) *agentv1.ProxyConfig {
+	var proxyConfig *agentv1.ProxyConfig
+	switch proxyPolicy {
+	case agentv1.ProxyPolicyKlusterlet:
+		if kc == nil || (kc.proxyConfig.HTTPProxy == "" && kc.proxyConfig.HTTPSProxy == "") {
+			return nil
+		}
+		// the proxy of the klusterlet is used to access the hub, so the hub hosts are not merged. The noProxy of the
+		// klusterlet is for the hub only, so the in-cluster destinations are merged instead.
+		proxyConfig = kc.proxyConfig.DeepCopy()
+		hubNoProxy = getInClusterNoProxy(config)
+	case agentv1.ProxyPolicyOCPGlobalProxy:
+		proxyConfig = config.Status.OCPGlobalProxy.DeepCopy()
+	case agentv1.ProxyPolicyCustomProxy:
+		proxyConfig = config.Spec.ProxyConfig.DeepCopy()
+	default:
+		return nil
+	}
+
+	if proxyConfig.HTTPProxy == "" && proxyConfig.HTTPSProxy == "" {
+		return proxyConfig
+	}
+	proxyConfig.NoProxy = mergeNoProxy(proxyConfig.NoProxy, hubNoProxy)
+	if agentConfig := getAddonAgentConfig(addonName, config); agentConfig != nil {
+		proxyConfig.NoProxy = mergeNoProxy(proxyConfig.NoProxy, agentConfig.AdditionalNoProxy)
+	}
+	return proxyConfig
+}
+
+func getProxyConfig(proxyConfig *agentv1.ProxyConfig) map[string]string {
+	if proxyConfig == nil {
+		return nil
+	}
+
+	// the credentials are passed to the addon by the proxyConfig secret
+	httpProxy, _ := helpers.SplitURLCredentials(proxyConfig.HTTPProxy)
+	httpsProxy, _ := helpers.SplitURLCredentials(proxyConfig.HTTPSProxy)
+	return map[string]string{
+		agentv1.HTTPProxy:  httpProxy,
+		agentv1.HTTPSProxy: httpsProxy,
+		agentv1.NoProxy:    proxyConfig.NoProxy,
+	}
+}
+
+// getProxyCredentials returns the proxyConfig with the credentials of the effective proxy config of the addon.
+// nil is returned if there are no credentials. The credentials secret is in the namespace.
+func (r *ReconcileKlusterletAddOn) getProxyCredentials(ctx context.Context, proxyConfig *agentv1.ProxyConfig,
+	namespace string) (map[string]string, error) {
+	if proxyConfig == nil {
+		return nil, nil
+	}
+
+	var username, password string
+	switch {
+	case proxyConfig.CredentialsSecret != nil:
+		secret := &corev1.Secret{}
+		err := r.client.Get(ctx, types.NamespacedName{Name: proxyConfig.CredentialsSecret.Name, Namespace: namespace},
+			secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the proxy credentials secret %s/%s. err:%v",
+				namespace, proxyConfig.CredentialsSecret.Name, err)
+		}
+		for _, key := range []string{corev1.BasicAuthUsernameKey, corev1.BasicAuthPasswordKey} {
+			if len(secret.Data[key]) == 0 {
+				return nil, fmt.Errorf("miss %s in the proxy credentials secret %s/%s", key, namespace,
+					proxyConfig.CredentialsSecret.Name)
+			}
+		}
+		username = string(secret.Data[corev1.BasicAuthUsernameKey])
+		password = string(secret.Data[corev1.BasicAuthPasswordKey])
+	default:
+		// the credentials are in the proxy URLs of the CustomProxy
+		for _, proxyURL := range []string{proxyConfig.HTTPSProxy, proxyConfig.HTTPProxy} {
+			if _, user := helpers.SplitURLCredentials(proxyURL); user != nil {
+				username = user.Username()
+				password, _ = user.Password()
+				break
+			}
+		}
+	}
+
+	if username == "" && password == "" {
+		return nil, nil
+	}
+
+	proxyCredentials := map[string]string{agentv1.NoProxy: proxyConfig.NoProxy}
+	if proxyConfig.HTTPProxy != "" {
+		proxyCredentials[agentv1.HTTPProxy] = helpers.SetURLCredentials(proxyConfig.HTTPProxy, username, password)
+	}
+	if proxyConfig.HTTPSProxy != "" {
+		proxyCredentials[agentv1.HTTPSProxy] = helpers.SetURLCredentials(proxyConfig.HTTPSProxy, username, password)
+	}
+	return proxyCredentials, nil
+}
+
+// applyProxyConfigSecret deploys the proxyConfig with the credentials as a secret in the install namespace of the
+// addon by manifestWork, and returns the name of the secret. The manifestWork is deleted if there are no
+// credentials for the addon.
+func (r *ReconcileKlusterletAddOn) applyProxyConfigSecret(ctx context.Context, addonName string,
+	proxyConfig *agentv1.ProxyConfig, namespace, clusterName, hostingClusterName string) (string, error) {
+	workName := addonWorkName(clusterName, addonName, proxyConfigSecretSuffix)
+	proxyCredentials, err := r.getProxyCredentials(ctx, proxyConfig, namespace)
+	if err != nil {
+		return "", err
+	}
+	if len(proxyCredentials) == 0 {
+		return "", r.deleteManifestWork(ctx, workName, clusterName, hostingClusterName, "")
+	}
+
+	workNamespace, installNamespace := getAddonNamespaces(addonName, clusterName, hostingClusterName)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", addonName, proxyConfigSecretSuffix),
+			Namespace: installNamespace,
+		},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: proxyCredentials,
+	}
+	work, err := newSecretManifestWork(workName, workNamespace, clusterName, addonName, secret)
+	if err != nil {
+		return "", err
+	}
+	if err := r.applyManifestWork(ctx, work); err != nil {
+		return "", err
+	}
+	return secret.Name, r.deleteManifestWork(ctx, workName, clusterName, hostingClusterName, workNamespace)
+}
+
+// getProxyCABundle returns the trusted CA bundle of the effective proxy config of the addon.
+// The configMap is in the namespace.
+func (r *ReconcileKlusterletAddOn) getProxyCABundle(ctx context.Context, proxyConfig *agentv1.ProxyConfig,
+	namespace string) (string, error) {
+	if proxyConfig == nil || proxyConfig.TrustedCABundle == nil {
+		return "", nil
+	}
+
+	ref := proxyConfig.TrustedCABundle
+	key := ref.Key
+	if key == "" {
+		key = agentv1.DefaultTrustedCABundleKey
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, configMap); err != nil {
+		return "", fmt.Errorf("failed to get the trusted CA bundle configmap %s/%s. err:%v", namespace, ref.Name, err)
+	}
+
+	caBundle, ok := configMap.Data[key]
+	if !ok {
+		return "", fmt.Errorf("miss %s in the trusted CA bundle configmap %s/%s", key, namespace, ref.Name)
+	}
+	return caBundle, nil
+}
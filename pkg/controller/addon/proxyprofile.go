@@ -0,0 +1,76 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"fmt"
+
+	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// proxyNamespaces are the namespaces of the trusted CA bundle configmap and the proxy credentials secret referenced
+// by a proxy config.
+type proxyNamespaces struct {
+	trustedCABundle   string
+	credentialsSecret string
+}
+
+// applyProxyProfile returns a copy of the klusterletAddonConfig whose ProxyConfig is replaced by the proxy config
+// of the ProxyProfile it references, the namespaces of the references of the ProxyConfig, and the
+// ProxyProfileResolved condition. The CredentialsSecret of the klusterletAddonConfig is kept if the ProxyProfile has
+// none. The klusterletAddonConfig itself is returned if it does not reference a ProxyProfile, or the ProxyProfile is
+// not found, then the inlined ProxyConfig is used. nil condition is returned if the klusterletAddonConfig does not
+// reference a ProxyProfile.
+func (r *ReconcileKlusterletAddOn) applyProxyProfile(ctx context.Context,
+	config *agentv1.KlusterletAddonConfig) (*agentv1.KlusterletAddonConfig, proxyNamespaces, *metav1.Condition, error) {
+	namespaces := proxyNamespaces{trustedCABundle: config.Namespace, credentialsSecret: config.Namespace}
+	if config.Spec.ProxyProfile == "" {
+		return config, namespaces, nil, nil
+	}
+
+	profile := &agentv1.ProxyProfile{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: config.Spec.ProxyProfile}, profile)
+	if errors.IsNotFound(err) {
+		return config, namespaces, &metav1.Condition{
+			Type:   agentv1.ProxyProfileResolved,
+			Status: metav1.ConditionFalse,
+			Reason: agentv1.ReasonProxyProfileNotFound,
+			Message: fmt.Sprintf("The proxyProfile %s is not found, the proxyConfig of the klusterletAddonConfig "+
+				"is used instead", config.Spec.ProxyProfile),
+		}, nil
+	}
+	if err != nil {
+		return nil, namespaces, nil, fmt.Errorf("failed to get the proxyProfile %s. err:%v",
+			config.Spec.ProxyProfile, err)
+	}
+
+	config = config.DeepCopy()
+	config.Spec.ProxyConfig = agentv1.ProxyConfig{
+		HTTPProxy:         profile.Spec.HTTPProxy,
+		HTTPSProxy:        profile.Spec.HTTPSProxy,
+		NoProxy:           profile.Spec.NoProxy,
+		CredentialsSecret: config.Spec.ProxyConfig.CredentialsSecret,
+	}
+	if ref := profile.Spec.TrustedCABundle; ref != nil {
+		config.Spec.ProxyConfig.TrustedCABundle = ref.ConfigMapReference.DeepCopy()
+		if ref.Namespace != "" {
+			namespaces.trustedCABundle = ref.Namespace
+		}
+	}
+	if ref := profile.Spec.CredentialsSecret; ref != nil {
+		config.Spec.ProxyConfig.CredentialsSecret = ref.SecretReference.DeepCopy()
+		if ref.Namespace != "" {
+			namespaces.credentialsSecret = ref.Namespace
+		}
+	}
+	return config, namespaces, &metav1.Condition{
+		Type:    agentv1.ProxyProfileResolved,
+		Status:  metav1.ConditionTrue,
+		Reason:  agentv1.ReasonProxyProfileFound,
+		Message: fmt.Sprintf("The proxy config of the proxyProfile %s is used", config.Spec.ProxyProfile),
+	}, nil
+}
@@ -0,0 +1,152 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	imageregistryv1alpha1 "github.com/stolostron/cluster-lifecycle-api/imageregistry/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// imagePullSecretSuffix is the name suffix of the image pull secret deployed in the install namespace of the addon
+	imagePullSecretSuffix = "image-pull-secret"
+
+	// addonImagePullSecretEnv is the env name of the image pull secret on the hub, in the format namespace/name,
+	// which is deployed to the install namespaces of the addons. The secret is in the namespace of the controller if
+	// the namespace is omitted. The pull secret of the managedClusterImageRegistry selecting the cluster takes
+	// precedence over it.
+	addonImagePullSecretEnv = "ADDON_IMAGE_PULL_SECRET"
+)
+
+// getHubImagePullSecret returns the namespace and the name of the image pull secret configured by the env.
+func getHubImagePullSecret() (string, string) {
+	pullSecret := strings.TrimSpace(os.Getenv(addonImagePullSecretEnv))
+	if pullSecret == "" {
+		return "", ""
+	}
+	if namespace, name, ok := strings.Cut(pullSecret, "/"); ok {
+		return namespace, name
+	}
+	return os.Getenv("POD_NAMESPACE"), pullSecret
+}
+
+// getImagePullSecretRef returns the namespace and the name of the image pull secret on the hub for the cluster. The
+// pull secret of the managedClusterImageRegistry is in the format namespace.name.
+func getImagePullSecretRef(imageRegistry *clusterImageRegistry) (string, string) {
+	if imageRegistry != nil && imageRegistry.imageRegistries.PullSecret != "" {
+		namespace, name, _ := strings.Cut(imageRegistry.imageRegistries.PullSecret, ".")
+		return namespace, name
+	}
+	return getHubImagePullSecret()
+}
+
+// applyImagePullSecret deploys the image pull secret on the hub in the install namespace of the addon by
+// manifestWork, and returns the name of the secret. The manifestWork is updated when the secret on the hub is
+// rotated, and deleted if there is no image pull secret for the cluster.
+func (r *ReconcileKlusterletAddOn) applyImagePullSecret(ctx context.Context, addonName string,
+	imageRegistry *clusterImageRegistry, clusterName, hostingClusterName string) (string, error) {
+	workName := addonWorkName(clusterName, addonName, imagePullSecretSuffix)
+	namespace, name := getImagePullSecretRef(imageRegistry)
+	if name == "" {
+		return "", r.deleteManifestWork(ctx, workName, clusterName, hostingClusterName, "")
+	}
+
+	pullSecret := &corev1.Secret{}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, pullSecret); err != nil {
+		return "", fmt.Errorf("failed to get the image pull secret %s/%s. err:%v", namespace, name, err)
+	}
+	if pullSecret.Type != corev1.SecretTypeDockerConfigJson {
+		return "", fmt.Errorf("the image pull secret %s/%s is not the type %s", namespace, name,
+			corev1.SecretTypeDockerConfigJson)
+	}
+
+	workNamespace, installNamespace := getAddonNamespaces(addonName, clusterName, hostingClusterName)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", addonName, imagePullSecretSuffix),
+			Namespace: installNamespace,
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: pullSecret.Data[corev1.DockerConfigJsonKey],
+		},
+	}
+	work, err := newSecretManifestWork(workName, workNamespace, clusterName, addonName, secret)
+	if err != nil {
+		return "", err
+	}
+	if err := r.applyManifestWork(ctx, work); err != nil {
+		return "", err
+	}
+	return secret.Name, r.deleteManifestWork(ctx, workName, clusterName, hostingClusterName, workNamespace)
+}
+
+// isImagePullSecret returns true if the secret is the image pull secret configured by the env or the pull secret of a
+// managedClusterImageRegistry.
+func isImagePullSecret(c client.Client, secret client.Object) bool {
+	if namespace, name := getHubImagePullSecret(); name == secret.GetName() && namespace == secret.GetNamespace() {
+		return true
+	}
+
+	registryList := &imageregistryv1alpha1.ManagedClusterImageRegistryList{}
+	err := c.List(context.TODO(), registryList, client.InNamespace(secret.GetNamespace()))
+	if meta.IsNoMatchError(err) {
+		return false
+	}
+	if err != nil {
+		klog.Errorf("failed to list managedClusterImageRegistries. err:%v", err)
+		return false
+	}
+	for _, registry := range registryList.Items {
+		if registry.Spec.PullSecret.Name == secret.GetName() {
+			return true
+		}
+	}
+	return false
+}
+
+// imagePullSecretRequests requeues the klusterletAddonConfigs whose addons use the image pull secret, so the
+// rotated secret is deployed to the managed clusters.
+func imagePullSecretRequests(c client.Client, secret client.Object) []reconcile.Request {
+	if namespace, name := getHubImagePullSecret(); name == secret.GetName() && namespace == secret.GetNamespace() {
+		return allKlusterletAddonConfigRequests(c)
+	}
+
+	registryList := &imageregistryv1alpha1.ManagedClusterImageRegistryList{}
+	err := c.List(context.TODO(), registryList, client.InNamespace(secret.GetNamespace()))
+	if meta.IsNoMatchError(err) {
+		return nil
+	}
+	if err != nil {
+		klog.Errorf("failed to list managedClusterImageRegistries. err:%v", err)
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	requested := map[types.NamespacedName]bool{}
+	for i := range registryList.Items {
+		registry := &registryList.Items[i]
+		if registry.Spec.PullSecret.Name != secret.GetName() {
+			continue
+		}
+		for _, request := range imageRegistryRequests(c, registry) {
+			if requested[request.NamespacedName] {
+				continue
+			}
+			requested[request.NamespacedName] = true
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
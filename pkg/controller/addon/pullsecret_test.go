@@ -0,0 +1,196 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package addon
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	imageregistryv1alpha1 "github.com/stolostron/cluster-lifecycle-api/imageregistry/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	workv1 "open-cluster-management.io/api/work/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newPullSecret(namespace, name, dockerConfig string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(dockerConfig)},
+	}
+}
+
+func Test_applyImagePullSecret(t *testing.T) {
+	testscheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(testscheme)
+	_ = workv1.AddToScheme(testscheme)
+
+	tests := []struct {
+		name                  string
+		env                   string
+		imageRegistry         *clusterImageRegistry
+		hostingClusterName    string
+		secrets               []runtime.Object
+		existingWorks         []runtime.Object
+		expectedErr           bool
+		expectedSecret        string
+		expectedWorkNamespace string
+		expectedNamespace     string
+		expectedDockerConfig  string
+	}{
+		{
+			name: "no image pull secret",
+			existingWorks: []runtime.Object{
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1-search-collector-image-pull-secret",
+						Namespace: "cluster1",
+						Labels: map[string]string{
+							workLabelCluster: "cluster1",
+							workLabelAddon:   "search-collector",
+						},
+					},
+				},
+			},
+		},
+		{
+			name:                  "hub image pull secret",
+			env:                   "ns1/pull-secret",
+			secrets:               []runtime.Object{newPullSecret("ns1", "pull-secret", "hub")},
+			expectedSecret:        "search-collector-image-pull-secret",
+			expectedWorkNamespace: "cluster1",
+			expectedNamespace:     "open-cluster-management-agent-addon",
+			expectedDockerConfig:  "hub",
+		},
+		{
+			name:                  "hub image pull secret in the controller namespace",
+			env:                   "pull-secret",
+			secrets:               []runtime.Object{newPullSecret("controller-ns", "pull-secret", "hub")},
+			expectedSecret:        "search-collector-image-pull-secret",
+			expectedWorkNamespace: "cluster1",
+			expectedNamespace:     "open-cluster-management-agent-addon",
+			expectedDockerConfig:  "hub",
+		},
+		{
+			name: "image pull secret of the managedClusterImageRegistry",
+			env:  "ns1/pull-secret",
+			imageRegistry: &clusterImageRegistry{
+				namespace:       "ns2",
+				name:            "registry",
+				imageRegistries: imageregistryv1alpha1.ImageRegistries{PullSecret: "ns2.registry-secret"},
+			},
+			secrets: []runtime.Object{
+				newPullSecret("ns1", "pull-secret", "hub"),
+				newPullSecret("ns2", "registry-secret", "registry"),
+			},
+			expectedSecret:        "search-collector-image-pull-secret",
+			expectedWorkNamespace: "cluster1",
+			expectedNamespace:     "open-cluster-management-agent-addon",
+			expectedDockerConfig:  "registry",
+		},
+		{
+			name:                  "hosted addon",
+			env:                   "ns1/pull-secret",
+			hostingClusterName:    "hosting",
+			secrets:               []runtime.Object{newPullSecret("ns1", "pull-secret", "hub")},
+			expectedSecret:        "config-policy-controller-image-pull-secret",
+			expectedWorkNamespace: "hosting",
+			expectedNamespace:     "klusterlet-cluster1",
+			expectedDockerConfig:  "hub",
+		},
+		{
+			name:               "hosted addon moved from the cluster namespace",
+			env:                "ns1/pull-secret",
+			hostingClusterName: "hosting",
+			secrets:            []runtime.Object{newPullSecret("ns1", "pull-secret", "hub")},
+			existingWorks: []runtime.Object{
+				&workv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cluster1-config-policy-controller-image-pull-secret",
+						Namespace: "cluster1",
+					},
+				},
+			},
+			expectedSecret:        "config-policy-controller-image-pull-secret",
+			expectedWorkNamespace: "hosting",
+			expectedNamespace:     "klusterlet-cluster1",
+			expectedDockerConfig:  "hub",
+		},
+		{
+			name:        "image pull secret not found",
+			env:         "ns1/pull-secret",
+			expectedErr: true,
+		},
+		{
+			name: "invalid image pull secret type",
+			env:  "ns1/pull-secret",
+			secrets: []runtime.Object{
+				&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "pull-secret", Namespace: "ns1"}},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(addonImagePullSecretEnv, tt.env)
+			t.Setenv("POD_NAMESPACE", "controller-ns")
+
+			addonName := "search-collector"
+			if tt.hostingClusterName != "" {
+				addonName = "config-policy-controller"
+			}
+			r := &ReconcileKlusterletAddOn{
+				client: fake.NewClientBuilder().WithScheme(testscheme).
+					WithRuntimeObjects(append(tt.existingWorks, tt.secrets...)...).Build(),
+			}
+			secretName, err := r.applyImagePullSecret(context.TODO(), addonName, tt.imageRegistry, "cluster1",
+				tt.hostingClusterName)
+			if tt.expectedErr != (err != nil) {
+				t.Fatalf("expected error %v, but got %v", tt.expectedErr, err)
+			}
+			if secretName != tt.expectedSecret {
+				t.Errorf("expected secret %q, but got %q", tt.expectedSecret, secretName)
+			}
+
+			works := &workv1.ManifestWorkList{}
+			if err := r.client.List(context.TODO(), works); err != nil {
+				t.Fatalf("failed to list manifestWorks. err:%v", err)
+			}
+			if tt.expectedSecret == "" {
+				if len(works.Items) != 0 {
+					t.Errorf("expected no manifestWork, but got %d", len(works.Items))
+				}
+				return
+			}
+			if len(works.Items) != 1 {
+				t.Errorf("expected one manifestWork, but got %d", len(works.Items))
+			}
+
+			work := &workv1.ManifestWork{}
+			err = r.client.Get(context.TODO(), types.NamespacedName{
+				Name:      "cluster1-" + addonName + "-image-pull-secret",
+				Namespace: tt.expectedWorkNamespace,
+			}, work)
+			if err != nil {
+				t.Fatalf("failed to get the manifestWork. err:%v", err)
+			}
+			secret := &corev1.Secret{}
+			if err := json.Unmarshal(work.Spec.Workload.Manifests[0].Raw, secret); err != nil {
+				t.Fatalf("failed to unmarshal the secret. err:%v", err)
+			}
+			if secret.Name != tt.expectedSecret || secret.Namespace != tt.expectedNamespace {
+				t.Errorf("expected secret %s/%s, but got %s/%s", tt.expectedNamespace, tt.expectedSecret,
+					secret.Namespace, secret.Name)
+			}
+			if string(secret.Data[corev1.DockerConfigJsonKey]) != tt.expectedDockerConfig {
+				t.Errorf("expected docker config %q, but got %q", tt.expectedDockerConfig,
+					secret.Data[corev1.DockerConfigJsonKey])
+			}
+		})
+	}
+}
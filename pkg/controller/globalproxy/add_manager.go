@@ -1,9 +1,14 @@
 package globalproxy
 
 import (
+	"context"
+	"os"
+
 	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
 	managedclusterv1 "open-cluster-management.io/api/cluster/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -61,5 +66,38 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	// requeue all of the klusterletAddonConfigs when the noProxy rules are changed
+	err = c.Watch(
+		&source.Kind{Type: &corev1.ConfigMap{}},
+		handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+			if obj.GetName() != noProxyRulesConfigMapName || obj.GetNamespace() != os.Getenv("POD_NAMESPACE") {
+				return nil
+			}
+			return allKlusterletAddonConfigRequests(mgr.GetClient())
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
+
+func allKlusterletAddonConfigRequests(c client.Client) []reconcile.Request {
+	configList := &agentv1.KlusterletAddonConfigList{}
+	if err := c.List(context.TODO(), configList); err != nil {
+		klog.Errorf("failed to list klusterletAddonConfigs. err:%v", err)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, config := range configList.Items {
+		if config.Name != config.Namespace {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: config.Name, Namespace: config.Namespace},
+		})
+	}
+	return requests
+}
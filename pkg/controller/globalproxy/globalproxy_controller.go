@@ -4,6 +4,7 @@ package globalproxy
 
 import (
 	"fmt"
+	"net/url"
 	"reflect"
 	"strings"
 	"time"
@@ -11,6 +12,7 @@ import (
 	"context"
 
 	agentv1 "github.com/stolostron/klusterlet-addon-controller/pkg/apis/agent/v1"
+	"github.com/stolostron/klusterlet-addon-controller/pkg/helpers"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -19,7 +21,6 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/util/retry"
@@ -31,6 +32,14 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+// trustedCABundleConfigMapSuffix is the name suffix of the configmap which contains the additionalTrustBundle
+// of the install config.
+const trustedCABundleConfigMapSuffix = "proxy-ca-bundle"
+
+// proxyCredentialsSecretSuffix is the name suffix of the secret which contains the credentials of the proxy URLs
+// in the install config.
+const proxyCredentialsSecretSuffix = "proxy-credentials"
+
 type GlobalProxyReconciler struct {
 	runtimeClient client.Client
 	kubeClient    kubernetes.Interface
@@ -61,6 +70,7 @@ func (r *GlobalProxyReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			newStatus.OCPGlobalProxy = agentv1.ProxyConfig{}
+			newStatus.InstallConfig = nil
 			meta.SetStatusCondition(&newStatus.Conditions, metav1.Condition{
 				Type:    agentv1.OCPGlobalProxyDetected,
 				Status:  metav1.ConditionFalse,
@@ -72,7 +82,16 @@ func (r *GlobalProxyReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return reconcile.Result{}, err
 	}
 
-	globalProxy, err := getGlobalProxyConfig(installConfigSecret)
+	// the platform facts are recorded even if there is no cluster-wide proxy config, the invalid install config is
+	// reported by the OCPGlobalProxyDetected condition.
+	newStatus.InstallConfig, _ = getInstallConfigStatus(installConfigSecret.Data["install-config.yaml"])
+
+	noProxyRules, err := r.getNoProxyRules(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	globalProxy, invalidNoProxy, err := getGlobalProxyConfig(installConfigSecret, noProxyRules)
 	if err != nil {
 		newStatus.OCPGlobalProxy = agentv1.ProxyConfig{}
 		meta.SetStatusCondition(&newStatus.Conditions, metav1.Condition{
@@ -95,14 +114,27 @@ func (r *GlobalProxyReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return reconcile.Result{}, r.updateStatus(req.Namespace, newStatus)
 	}
 
-	if !reflect.DeepEqual(newStatus.OCPGlobalProxy, globalProxy) {
-		newStatus.OCPGlobalProxy = globalProxy
-		meta.SetStatusCondition(&newStatus.Conditions, metav1.Condition{
-			Type:    agentv1.OCPGlobalProxyDetected,
-			Status:  metav1.ConditionTrue,
-			Reason:  agentv1.ReasonOCPGlobalProxyDetected,
-			Message: "Detected the cluster-wide proxy config in install config.",
-		})
+	if err := r.applyTrustedCABundle(ctx, klusterletAddonConfig, installConfigSecret, &globalProxy); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	if err := r.applyProxyCredentials(ctx, klusterletAddonConfig, &globalProxy); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	message := "Detected the cluster-wide proxy config in install config."
+	if len(invalidNoProxy) != 0 {
+		message = fmt.Sprintf("%s The invalid noProxy entries are ignored: %s.", message,
+			strings.Join(invalidNoProxy, ","))
+	}
+	newStatus.OCPGlobalProxy = globalProxy
+	meta.SetStatusCondition(&newStatus.Conditions, metav1.Condition{
+		Type:    agentv1.OCPGlobalProxyDetected,
+		Status:  metav1.ConditionTrue,
+		Reason:  agentv1.ReasonOCPGlobalProxyDetected,
+		Message: message,
+	})
+	if !equality.Semantic.DeepEqual(klusterletAddonConfig.Status, *newStatus) {
 		return reconcile.Result{}, r.updateStatus(req.Namespace, newStatus)
 	}
 
@@ -127,6 +159,7 @@ func (r *GlobalProxyReconciler) updateStatus(clusterName string, status *agentv1
 
 		newStatus := klusterletAddonConfig.Status.DeepCopy()
 		newStatus.OCPGlobalProxy = status.OCPGlobalProxy
+		newStatus.InstallConfig = status.InstallConfig
 		for _, condition := range status.Conditions {
 			meta.SetStatusCondition(&newStatus.Conditions, condition)
 		}
@@ -139,19 +172,139 @@ func (r *GlobalProxyReconciler) updateStatus(clusterName string, status *agentv1
 	return err
 }
 
-// getGlobalProxyConfig gets proxyConfig from install-config secret
-func getGlobalProxyConfig(installConfigSecret *corev1.Secret) (agentv1.ProxyConfig, error) {
+// applyTrustedCABundle syncs the additionalTrustBundle of the install config to the trusted CA bundle configmap
+// of the cluster, and references the configmap in the globalProxy. The configmap is deleted if there is no
+// additionalTrustBundle in the install config.
+func (r *GlobalProxyReconciler) applyTrustedCABundle(ctx context.Context, config *agentv1.KlusterletAddonConfig,
+	installConfigSecret *corev1.Secret, globalProxy *agentv1.ProxyConfig) error {
+	name := fmt.Sprintf("%s-%s", config.Name, trustedCABundleConfigMapSuffix)
+	trustBundle, err := getAdditionalTrustBundle(installConfigSecret.Data["install-config.yaml"])
+	if err != nil {
+		return err
+	}
+
+	if trustBundle == "" {
+		err := r.kubeClient.CoreV1().ConfigMaps(config.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	configMap, err := r.kubeClient.CoreV1().ConfigMaps(config.Namespace).Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: config.Namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(config, agentv1.SchemeGroupVersion.WithKind("KlusterletAddonConfig")),
+				},
+			},
+			Data: map[string]string{agentv1.DefaultTrustedCABundleKey: trustBundle},
+		}
+		if _, err := r.kubeClient.CoreV1().ConfigMaps(config.Namespace).Create(ctx, configMap, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case configMap.Data[agentv1.DefaultTrustedCABundleKey] != trustBundle:
+		configMap = configMap.DeepCopy()
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[agentv1.DefaultTrustedCABundleKey] = trustBundle
+		if _, err := r.kubeClient.CoreV1().ConfigMaps(config.Namespace).Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+
+	globalProxy.TrustedCABundle = &agentv1.ConfigMapReference{
+		Name: name,
+		Key:  agentv1.DefaultTrustedCABundleKey,
+	}
+	return nil
+}
+
+// applyProxyCredentials moves the credentials in the proxy URLs of the globalProxy to the proxy credentials secret
+// of the cluster, and references the secret in the globalProxy. The URLs in the globalProxy are redacted since they
+// are shown in the status. The secret is deleted if there are no credentials in the proxy URLs.
+func (r *GlobalProxyReconciler) applyProxyCredentials(ctx context.Context, config *agentv1.KlusterletAddonConfig,
+	globalProxy *agentv1.ProxyConfig) error {
+	name := fmt.Sprintf("%s-%s", config.Name, proxyCredentialsSecretSuffix)
+
+	var user *url.Userinfo
+	for _, proxyURL := range []*string{&globalProxy.HTTPSProxy, &globalProxy.HTTPProxy} {
+		_, proxyUser := helpers.SplitURLCredentials(*proxyURL)
+		if proxyUser == nil {
+			continue
+		}
+		if user == nil {
+			user = proxyUser
+		}
+		*proxyURL = helpers.RedactURL(*proxyURL)
+	}
+
+	if user == nil {
+		err := r.kubeClient.CoreV1().Secrets(config.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	password, _ := user.Password()
+	data := map[string][]byte{
+		corev1.BasicAuthUsernameKey: []byte(user.Username()),
+		corev1.BasicAuthPasswordKey: []byte(password),
+	}
+	secret, err := r.kubeClient.CoreV1().Secrets(config.Namespace).Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: config.Namespace,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(config, agentv1.SchemeGroupVersion.WithKind("KlusterletAddonConfig")),
+				},
+			},
+			Type: corev1.SecretTypeBasicAuth,
+			Data: data,
+		}
+		if _, err := r.kubeClient.CoreV1().Secrets(config.Namespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case !reflect.DeepEqual(secret.Data, data):
+		secret = secret.DeepCopy()
+		secret.Data = data
+		if _, err := r.kubeClient.CoreV1().Secrets(config.Namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+
+	globalProxy.CredentialsSecret = &agentv1.SecretReference{Name: name}
+	return nil
+}
+
+// getGlobalProxyConfig gets proxyConfig and the invalid noProxy entries from install-config secret
+func getGlobalProxyConfig(installConfigSecret *corev1.Secret,
+	noProxyRules map[string]noProxyRule) (agentv1.ProxyConfig, []string, error) {
 	proxyConfig := agentv1.ProxyConfig{}
 	if len(installConfigSecret.Data) == 0 {
-		return proxyConfig, fmt.Errorf("miss Data in install config secret %v", installConfigSecret.Name)
+		return proxyConfig, nil, fmt.Errorf("miss Data in install config secret %v", installConfigSecret.Name)
 	}
 
 	installConfigData, ok := installConfigSecret.Data["install-config.yaml"]
 	if !ok {
-		return proxyConfig, fmt.Errorf("miss install-config.yaml in install config secret %v", installConfigSecret.Name)
+		return proxyConfig, nil, fmt.Errorf("miss install-config.yaml in install config secret %v",
+			installConfigSecret.Name)
 	}
 
-	return getGlobalProxyInInstallConfig(installConfigData)
+	return getGlobalProxyInInstallConfig(installConfigData, noProxyRules)
 }
 
 func getClusterNetworkCIDRs(proxyConfigRaw map[string]interface{}) ([]string, error) {
@@ -199,107 +352,127 @@ func getServiceNetworkCIDRs(proxyConfigRaw map[string]interface{}) ([]string, er
 	return cidrs, nil
 }
 
-// For installations on Amazon Web Services (AWS), Google Cloud Platform (GCP), Microsoft Azure,
-// and Red Hat OpenStack Platform (RHOSP), the Proxy object status.noProxy field is also populated
-// with the instance metadata endpoint (169.254.169.254).
-// ref: https://docs.openshift.com/container-platform/4.9/networking/enable-cluster-wide-proxy.html
-func getMetaDataEndpoint(proxyConfigRaw map[string]interface{}) string {
-	platforms := []string{"aws", "azure", "gcp"}
-	for _, platform := range platforms {
-		_, found, _ := unstructured.NestedString(proxyConfigRaw, "platform", platform, "region")
-		if found {
-			return "169.254.169.254"
-		}
-	}
-	_, found, _ := unstructured.NestedString(proxyConfigRaw, "platform", "openstack", "externalNetwork")
-	if found {
-		return "169.254.169.254"
+// getAdditionalTrustBundle gets the PEM-encoded additionalTrustBundle from install-config.yaml
+func getAdditionalTrustBundle(installConfig []byte) (string, error) {
+	installConfigRaw := map[string]interface{}{}
+	if err := yaml.Unmarshal(installConfig, &installConfigRaw); err != nil {
+		return "", err
 	}
-	return ""
-}
 
-// refer: https://github.com/openshift/installer/blob/master/docs/design/baremetal/networking-infrastructure.md#internal-dns
-func getHostName(proxyConfigRaw map[string]interface{}) (string, error) {
-	clusterName, _, err := unstructured.NestedString(proxyConfigRaw, "metadata", "name")
+	trustBundle, _, err := unstructured.NestedString(installConfigRaw, "additionalTrustBundle")
 	if err != nil {
 		return "", err
 	}
+	if strings.TrimSpace(trustBundle) == "" {
+		return "", nil
+	}
+	return trustBundle, nil
+}
+
+// getInstallConfigStatus gets the platform facts from install-config.yaml, nil is returned if install-config.yaml
+// is empty.
+func getInstallConfigStatus(installConfig []byte) (*agentv1.InstallConfigStatus, error) {
+	if len(installConfig) == 0 {
+		return nil, nil
+	}
+
+	installConfigRaw := map[string]interface{}{}
+	if err := yaml.Unmarshal(installConfig, &installConfigRaw); err != nil {
+		return nil, err
+	}
 
-	baseDomain, _, err := unstructured.NestedString(proxyConfigRaw, "baseDomain")
+	status := &agentv1.InstallConfigStatus{Platform: getPlatform(installConfigRaw)}
+	var err error
+	if status.Platform != "" {
+		status.Region, _, err = unstructured.NestedString(installConfigRaw, "platform", status.Platform, "region")
+		if err != nil {
+			return nil, err
+		}
+	}
+	status.BaseDomain, _, err = unstructured.NestedString(installConfigRaw, "baseDomain")
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return fmt.Sprintf("api-int.%s.%s", clusterName, baseDomain), nil
+	status.NetworkType, _, err = unstructured.NestedString(installConfigRaw, "networking", "networkType")
+	if err != nil {
+		return nil, err
+	}
+	if status.ClusterNetwork, err = getClusterNetworkCIDRs(installConfigRaw); err != nil {
+		return nil, err
+	}
+	if status.MachineNetwork, err = getMachineNetworkCIDRs(installConfigRaw); err != nil {
+		return nil, err
+	}
+	if status.ServiceNetwork, err = getServiceNetworkCIDRs(installConfigRaw); err != nil {
+		return nil, err
+	}
+	return status, nil
 }
 
-// getGlobalProxyInInstallConfig gets proxyConfig from install-config.yaml
+// getGlobalProxyInInstallConfig gets proxyConfig from install-config.yaml, and the invalid noProxy entries which
+// are dropped from the noProxy. The platform specific noProxy entries are added by the noProxyRules.
 // refer: https://github.com/openshift/installer/blob/master/docs/user/customization.md#proxy
-func getGlobalProxyInInstallConfig(installConfig []byte) (agentv1.ProxyConfig, error) {
-	proxyConfig := agentv1.ProxyConfig{}
+func getGlobalProxyInInstallConfig(installConfig []byte, noProxyRules map[string]noProxyRule) (
+	proxyConfig agentv1.ProxyConfig, invalidNoProxy []string, err error) {
 	proxyConfigRaw := map[string]interface{}{}
 
-	err := yaml.Unmarshal(installConfig, &proxyConfigRaw)
+	err = yaml.Unmarshal(installConfig, &proxyConfigRaw)
 	if err != nil {
-		return proxyConfig, err
+		return proxyConfig, nil, err
 	}
 
 	// proxy defined in https://github.com/openshift/installer/blob/master/pkg/types/installconfig.go
 	proxyConfig.HTTPSProxy, _, err = unstructured.NestedString(proxyConfigRaw, "proxy", "httpsProxy")
 	if err != nil {
-		return proxyConfig, err
+		return proxyConfig, nil, err
 	}
 	proxyConfig.HTTPProxy, _, err = unstructured.NestedString(proxyConfigRaw, "proxy", "httpProxy")
 	if err != nil {
-		return proxyConfig, err
+		return proxyConfig, nil, err
 	}
 
 	noProxy, _, err := unstructured.NestedString(proxyConfigRaw, "proxy", "noProxy")
 	if err != nil {
-		return proxyConfig, err
+		return proxyConfig, nil, err
 	}
 
 	if proxyConfig.HTTPProxy == "" && proxyConfig.HTTPSProxy == "" && noProxy == "" {
-		return proxyConfig, nil
+		return proxyConfig, nil, nil
 	}
 
 	if noProxy == "*" {
 		proxyConfig.NoProxy = noProxy
-		return proxyConfig, nil
+		return proxyConfig, nil, nil
 	}
 
 	// The noProxy field needs to be populated with the values of some default DNS address, networking.machineNetwork[].cidr,
 	// networking.clusterNetwork[].cidr, and networking.serviceNetwork[] fields from the installation configuration.
 	// should be the same with the status.noProxy in proxy.config.openshift.io/cluster.
-	noProxyList := sets.NewString(".cluster.local", ".svc", "localhost", "127.0.0.1")
-	noProxyList.Insert(noProxy)
+	// The entries of both IPv4 and IPv6 are validated and canonicalized, and the invalid entries are returned
+	// instead of being passed to the addons.
+	noProxyList := newNoProxyBuilder()
+	noProxyList.insert(noProxy)
 
 	clusterNetworkCIDRs, err := getClusterNetworkCIDRs(proxyConfigRaw)
 	if err != nil {
-		return proxyConfig, err
+		return proxyConfig, nil, err
 	}
-	noProxyList.Insert(clusterNetworkCIDRs...)
+	noProxyList.insert(clusterNetworkCIDRs...)
 
 	machineNetworkCIDRs, err := getMachineNetworkCIDRs(proxyConfigRaw)
 	if err != nil {
-		return proxyConfig, err
+		return proxyConfig, nil, err
 	}
-	noProxyList.Insert(machineNetworkCIDRs...)
+	noProxyList.insert(machineNetworkCIDRs...)
 
 	serviceNetworkCIDRs, err := getServiceNetworkCIDRs(proxyConfigRaw)
 	if err != nil {
-		return proxyConfig, err
-	}
-	noProxyList.Insert(serviceNetworkCIDRs...)
-
-	hostName, err := getHostName(proxyConfigRaw)
-	if err != nil {
-		return proxyConfig, err
+		return proxyConfig, nil, err
 	}
-	noProxyList.Insert(hostName)
+	noProxyList.insert(serviceNetworkCIDRs...)
 
-	noProxyList.Insert(getMetaDataEndpoint(proxyConfigRaw))
+	noProxyList.insert(getNoProxyByRules(proxyConfigRaw, noProxyRules)...)
 
-	noProxyList.Delete("")
-	proxyConfig.NoProxy = strings.Join(noProxyList.List(), ",")
-	return proxyConfig, nil
+	proxyConfig.NoProxy, invalidNoProxy = noProxyList.build()
+	return proxyConfig, invalidNoProxy, nil
 }
@@ -0,0 +1,130 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package globalproxy
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// defaultNoProxy is the noProxy entries which are always added, it should be the same with the
+// status.noProxy in proxy.config.openshift.io/cluster.
+var defaultNoProxy = []string{".cluster.local", ".svc", "localhost", "127.0.0.1", "::1"}
+
+// noProxyBuilder builds the noProxy from the entries of both IPv4 and IPv6. The entries are validated and
+// canonicalized, the duplicated entries and the IPs and CIDRs contained by other CIDRs are dropped.
+type noProxyBuilder struct {
+	hosts    sets.String
+	ips      map[string]net.IP
+	networks map[string]*net.IPNet
+	invalid  []string
+}
+
+func newNoProxyBuilder() *noProxyBuilder {
+	return &noProxyBuilder{
+		hosts:    sets.NewString(),
+		ips:      map[string]net.IP{},
+		networks: map[string]*net.IPNet{},
+	}
+}
+
+// insert adds the entries, each of the entries can be a comma-separated list of hostnames, domains, IPs and CIDRs.
+func (b *noProxyBuilder) insert(entries ...string) {
+	for _, entry := range entries {
+		for _, item := range strings.Split(entry, ",") {
+			b.insertItem(strings.TrimSpace(item))
+		}
+	}
+}
+
+func (b *noProxyBuilder) insertItem(item string) {
+	if item == "" {
+		return
+	}
+
+	if strings.Contains(item, "/") {
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			b.invalid = append(b.invalid, item)
+			return
+		}
+		b.networks[network.String()] = network
+		return
+	}
+
+	// the entry with a port, like registry.example.com:5000 or [fd00::1]:5000, is kept as it is if both of its host
+	// and port are valid.
+	if host, port, err := net.SplitHostPort(item); err == nil {
+		portNum, err := strconv.Atoi(port)
+		validPort := err == nil && len(validation.IsValidPortNum(portNum)) == 0
+		if !validPort || (net.ParseIP(host) == nil && !isValidHost(host)) {
+			b.invalid = append(b.invalid, item)
+			return
+		}
+		b.hosts.Insert(item)
+		return
+	}
+
+	if ip := net.ParseIP(item); ip != nil {
+		b.ips[ip.String()] = ip
+		return
+	}
+
+	host := strings.ToLower(item)
+	if !isValidHost(host) {
+		b.invalid = append(b.invalid, item)
+		return
+	}
+	b.hosts.Insert(host)
+}
+
+// isValidHost returns true if the host is a valid hostname or domain. The leading dot matches the subdomains, and
+// the trailing dot is the fully qualified domain name.
+func isValidHost(host string) bool {
+	return len(validation.IsDNS1123Subdomain(strings.Trim(strings.ToLower(host), "."))) == 0
+}
+
+// build returns the sorted comma-separated noProxy, and the invalid entries.
+func (b *noProxyBuilder) build() (string, []string) {
+	noProxy := sets.NewString(b.hosts.UnsortedList()...)
+	for key, network := range b.networks {
+		if !containedByOtherNetwork(network, b.networks) {
+			noProxy.Insert(key)
+		}
+	}
+	for key, ip := range b.ips {
+		if !containedByNetwork(ip, b.networks) {
+			noProxy.Insert(key)
+		}
+	}
+	return strings.Join(noProxy.List(), ","), b.invalid
+}
+
+// containedByNetwork returns true if the ip is in one of the networks.
+func containedByNetwork(ip net.IP, networks map[string]*net.IPNet) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// containedByOtherNetwork returns true if the network is a subnet of another network in the networks.
+func containedByOtherNetwork(network *net.IPNet, networks map[string]*net.IPNet) bool {
+	ones, bits := network.Mask.Size()
+	for _, other := range networks {
+		otherOnes, otherBits := other.Mask.Size()
+		if otherBits != bits || otherOnes >= ones {
+			continue
+		}
+		if other.Contains(network.IP) {
+			return true
+		}
+	}
+	return false
+}
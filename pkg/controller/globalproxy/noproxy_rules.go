@@ -0,0 +1,283 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package globalproxy
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// noProxyRulesConfigMapName is the name of the configmap in the namespace of the controller, which extends or
+	// replaces the built-in noProxy rules. Each key is a platform name, or commonNoProxyRule, and each value is a
+	// noProxyRule in yaml.
+	noProxyRulesConfigMapName = "klusterlet-addon-noproxy-rules"
+
+	// commonNoProxyRule is the key of the noProxy rule which applies to all of the platforms
+	commonNoProxyRule = "common"
+)
+
+// noProxyRule defines the noProxy entries which are added for a platform. The paths are the dot-separated paths of
+// the fields in the install config, the lists in a path are iterated, like platform.vsphere.vcenters.server.
+type noProxyRule struct {
+	// Entries are added to the noProxy.
+	Entries []string `json:"entries,omitempty"`
+
+	// Fields are the paths of the fields whose string or string list values are added to the noProxy.
+	Fields []string `json:"fields,omitempty"`
+
+	// Templates are added to the noProxy with each {path} replaced by the value of the field.
+	// A template is skipped if one of its fields is not set.
+	Templates []string `json:"templates,omitempty"`
+
+	// Overrides replace the Entries and Templates if the field has the value, the keys are path=value.
+	Overrides map[string][]string `json:"overrides,omitempty"`
+
+	// Replace is only used by the rules in the noProxy rules configmap. The built-in rule of the same key is replaced
+	// by the rule if it is true, otherwise the rule is merged into the built-in rule.
+	Replace bool `json:"replace,omitempty"`
+}
+
+// merge returns the rule with the entries, fields, templates and overrides of the other rule appended, the
+// overrides of the other rule take precedence.
+func (rule noProxyRule) merge(other noProxyRule) noProxyRule {
+	merged := noProxyRule{
+		Entries:   appendMissing(rule.Entries, other.Entries),
+		Fields:    appendMissing(rule.Fields, other.Fields),
+		Templates: appendMissing(rule.Templates, other.Templates),
+	}
+	if len(rule.Overrides)+len(other.Overrides) != 0 {
+		merged.Overrides = map[string][]string{}
+		for key, entries := range rule.Overrides {
+			merged.Overrides[key] = entries
+		}
+		for key, entries := range other.Overrides {
+			merged.Overrides[key] = entries
+		}
+	}
+	return merged
+}
+
+// appendMissing returns a new list of the items with the other items which are not in the items appended.
+func appendMissing(items, others []string) []string {
+	if len(items)+len(others) == 0 {
+		return nil
+	}
+	merged := append([]string{}, items...)
+	existing := sets.NewString(items...)
+	for _, item := range others {
+		if !existing.Has(item) {
+			merged = append(merged, item)
+			existing.Insert(item)
+		}
+	}
+	return merged
+}
+
+// vipFields returns the paths of the VIP fields of the platform
+func vipFields(platform string) []string {
+	return []string{
+		"platform." + platform + ".apiVIP",
+		"platform." + platform + ".apiVIPs",
+		"platform." + platform + ".ingressVIP",
+		"platform." + platform + ".ingressVIPs",
+	}
+}
+
+// defaultNoProxyRules should result in the same noProxy with the status.noProxy in proxy.config.openshift.io/cluster.
+// refer: https://github.com/openshift/cluster-network-operator/blob/master/pkg/util/proxyconfig/no_proxy.go
+var defaultNoProxyRules = map[string]noProxyRule{
+	commonNoProxyRule: {
+		Entries: defaultNoProxy,
+		// refer: https://github.com/openshift/installer/blob/master/docs/design/baremetal/networking-infrastructure.md#internal-dns
+		Templates: []string{"api-int.{metadata.name}.{baseDomain}"},
+	},
+	"aws": {
+		Entries:   []string{"169.254.169.254"},
+		Templates: []string{".{platform.aws.region}.compute.internal"},
+		Overrides: map[string][]string{
+			"platform.aws.region=us-east-1": {"169.254.169.254", ".ec2.internal"},
+		},
+	},
+	"azure": {
+		Entries: []string{"169.254.169.254"},
+		// there is no instance metadata endpoint on Azure Stack Hub
+		Overrides: map[string][]string{
+			"platform.azure.cloudName=AzureStackCloud": {},
+		},
+	},
+	"gcp": {
+		Entries: []string{"169.254.169.254", "metadata", "metadata.google.internal", "metadata.google.internal."},
+	},
+	"openstack": {
+		Entries: []string{"169.254.169.254"},
+		Fields:  vipFields("openstack"),
+	},
+	"alibabacloud": {
+		Entries: []string{"100.100.100.200"},
+	},
+	"nutanix":   {Fields: append(vipFields("nutanix"), "platform.nutanix.prismCentral.endpoint.address")},
+	"vsphere":   {Fields: append(vipFields("vsphere"), "platform.vsphere.vCenter", "platform.vsphere.vcenters.server")},
+	"baremetal": {Fields: vipFields("baremetal")},
+	// cluster-network-operator adds nothing beyond the common rule for IBM Cloud and Power VS, the empty rules keep
+	// the platforms known, so their rules in the noProxy rules configmap are merged like the other platforms.
+	"ibmcloud": {},
+	"powervs":  {},
+}
+
+var templateFieldRegexp = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// getNoProxyRules returns the built-in noProxy rules, which are extended by the rules in the noProxy rules
+// configmap, or replaced by the rules with replace: true. The invalid rules in the configmap are ignored.
+func (r *GlobalProxyReconciler) getNoProxyRules(ctx context.Context) (map[string]noProxyRule, error) {
+	rules := map[string]noProxyRule{}
+	for platform, rule := range defaultNoProxyRules {
+		rules[platform] = rule
+	}
+
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		return rules, nil
+	}
+
+	configMap, err := r.kubeClient.CoreV1().ConfigMaps(namespace).Get(ctx, noProxyRulesConfigMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return rules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for platform, data := range configMap.Data {
+		rule := noProxyRule{}
+		if err := yaml.Unmarshal([]byte(data), &rule); err != nil {
+			klog.Errorf("failed to unmarshal the noProxy rule of %s in configmap %s/%s. err:%v",
+				platform, namespace, noProxyRulesConfigMapName, err)
+			continue
+		}
+		if builtin, ok := rules[platform]; ok && !rule.Replace {
+			rule = builtin.merge(rule)
+		}
+		rule.Replace = false
+		rules[platform] = rule
+	}
+	return rules, nil
+}
+
+// getPlatform returns the platform name of the install config, like aws, baremetal and none.
+func getPlatform(installConfigRaw map[string]interface{}) string {
+	platforms, ok := installConfigRaw["platform"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	names := make([]string, 0, len(platforms))
+	for name := range platforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+// getNoProxyByRules returns the noProxy entries of the common rule and the rule of the platform in the install config.
+func getNoProxyByRules(installConfigRaw map[string]interface{}, rules map[string]noProxyRule) []string {
+	var entries []string
+	for _, name := range []string{commonNoProxyRule, getPlatform(installConfigRaw)} {
+		rule, ok := rules[name]
+		if !ok {
+			continue
+		}
+		entries = append(entries, rule.apply(installConfigRaw)...)
+	}
+	return entries
+}
+
+func (rule noProxyRule) apply(installConfigRaw map[string]interface{}) []string {
+	var entries []string
+	for _, path := range rule.Fields {
+		entries = append(entries, getFieldValues(installConfigRaw, path)...)
+	}
+
+	keys := make([]string, 0, len(rule.Overrides))
+	for key := range rule.Overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		path, value, _ := strings.Cut(key, "=")
+		for _, fieldValue := range getFieldValues(installConfigRaw, path) {
+			if fieldValue == value {
+				return append(entries, rule.Overrides[key]...)
+			}
+		}
+	}
+
+	entries = append(entries, rule.Entries...)
+	for _, template := range rule.Templates {
+		if entry, ok := applyTemplate(installConfigRaw, template); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// applyTemplate replaces each {path} in the template by the value of the field, false is returned if one of the
+// fields is not set.
+func applyTemplate(installConfigRaw map[string]interface{}, template string) (string, bool) {
+	resolved := true
+	entry := templateFieldRegexp.ReplaceAllStringFunc(template, func(placeholder string) string {
+		values := getFieldValues(installConfigRaw, strings.Trim(placeholder, "{}"))
+		if len(values) == 0 || values[0] == "" {
+			resolved = false
+			return ""
+		}
+		return values[0]
+	})
+	return entry, resolved
+}
+
+// getFieldValues returns the string values of the field in the dot-separated path, the lists in the path are iterated.
+func getFieldValues(obj interface{}, path string) []string {
+	if path == "" {
+		switch v := obj.(type) {
+		case string:
+			return []string{v}
+		case []interface{}:
+			var values []string
+			for _, item := range v {
+				values = append(values, getFieldValues(item, "")...)
+			}
+			return values
+		}
+		return nil
+	}
+
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		key, rest, _ := strings.Cut(path, ".")
+		field, ok := v[key]
+		if !ok {
+			return nil
+		}
+		return getFieldValues(field, rest)
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			values = append(values, getFieldValues(item, path)...)
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package globalproxy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_getNoProxyByRules(t *testing.T) {
+	var testCases = []struct {
+		name            string
+		platform        string
+		installConfig   string
+		expectedNoProxy []string
+	}{
+		{
+			name:     "aws",
+			platform: "aws",
+			installConfig: `
+platform:
+  aws:
+    region: us-west-2
+`,
+			expectedNoProxy: []string{"169.254.169.254", ".us-west-2.compute.internal"},
+		},
+		{
+			name:     "aws us-east-1",
+			platform: "aws",
+			installConfig: `
+platform:
+  aws:
+    region: us-east-1
+`,
+			expectedNoProxy: []string{"169.254.169.254", ".ec2.internal"},
+		},
+		{
+			name:     "azure stack hub",
+			platform: "azure",
+			installConfig: `
+platform:
+  azure:
+    cloudName: AzureStackCloud
+`,
+			expectedNoProxy: nil,
+		},
+		{
+			name:     "alibabacloud",
+			platform: "alibabacloud",
+			installConfig: `
+platform:
+  alibabacloud:
+    region: cn-hangzhou
+`,
+			expectedNoProxy: []string{"100.100.100.200"},
+		},
+		{
+			name:     "baremetal",
+			platform: "baremetal",
+			installConfig: `
+platform:
+  baremetal:
+    apiVIPs:
+    - 192.168.111.5
+    - fd2e:6f44:5dd8:c956::5
+    ingressVIPs:
+    - 192.168.111.4
+`,
+			expectedNoProxy: []string{"192.168.111.5", "fd2e:6f44:5dd8:c956::5", "192.168.111.4"},
+		},
+		{
+			name:     "vsphere",
+			platform: "vsphere",
+			installConfig: `
+platform:
+  vsphere:
+    apiVIP: 10.0.0.5
+    ingressVIP: 10.0.0.6
+    vcenters:
+    - server: vcenter1.example.com
+    - server: vcenter2.example.com
+`,
+			expectedNoProxy: []string{"10.0.0.5", "10.0.0.6", "vcenter1.example.com", "vcenter2.example.com"},
+		},
+		{
+			name:     "nutanix",
+			platform: "nutanix",
+			installConfig: `
+platform:
+  nutanix:
+    apiVIP: 10.0.0.5
+    prismCentral:
+      endpoint:
+        address: prism.example.com
+`,
+			expectedNoProxy: []string{"10.0.0.5", "prism.example.com"},
+		},
+		{
+			name:     "ibmcloud",
+			platform: "ibmcloud",
+			installConfig: `
+platform:
+  ibmcloud:
+    region: us-south
+`,
+			expectedNoProxy: nil,
+		},
+		{
+			name:     "powervs",
+			platform: "powervs",
+			installConfig: `
+platform:
+  powervs:
+    region: dal
+`,
+			expectedNoProxy: nil,
+		},
+		{
+			name:     "platform without a rule",
+			platform: "libvirt",
+			installConfig: `
+platform:
+  libvirt:
+    URI: qemu+tcp://192.168.122.1/system
+`,
+			expectedNoProxy: nil,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			installConfigRaw := map[string]interface{}{}
+			if err := yaml.Unmarshal([]byte(c.installConfig), &installConfigRaw); err != nil {
+				t.Fatalf("failed to unmarshal install config. err:%v", err)
+			}
+			if platform := getPlatform(installConfigRaw); platform != c.platform {
+				t.Errorf("expected platform %q, but got %q", c.platform, platform)
+			}
+
+			noProxy := getNoProxyByRules(installConfigRaw, map[string]noProxyRule{
+				c.platform: defaultNoProxyRules[c.platform],
+			})
+			if !reflect.DeepEqual(noProxy, c.expectedNoProxy) {
+				t.Errorf("expected noProxy %v, but got %v", c.expectedNoProxy, noProxy)
+			}
+		})
+	}
+}
+
+func Test_getNoProxyRules(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "open-cluster-management")
+
+	r := &GlobalProxyReconciler{
+		kubeClient: kubefake.NewSimpleClientset(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      noProxyRulesConfigMapName,
+				Namespace: "open-cluster-management",
+			},
+			Data: map[string]string{
+				"common": `
+entries:
+- .svc
+- registry.example.com
+templates:
+- api.{metadata.name}.{baseDomain}
+`,
+				"aws": `
+replace: true
+entries:
+- 169.254.169.254
+`,
+				"external": `
+entries:
+- metadata.example.com
+`,
+				"invalid": "entries: metadata.example.com",
+			},
+		}),
+	}
+
+	rules, err := r.getNoProxyRules(context.TODO())
+	if err != nil {
+		t.Fatalf("expected no err but got %v", err)
+	}
+
+	installConfigRaw := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(`
+metadata:
+  name: cluster
+baseDomain: example.com
+platform:
+  external:
+    platformName: oci
+`), &installConfigRaw); err != nil {
+		t.Fatalf("failed to unmarshal install config. err:%v", err)
+	}
+
+	expectedNoProxy := []string{".cluster.local", ".svc", "localhost", "127.0.0.1", "::1", "registry.example.com",
+		"api-int.cluster.example.com", "api.cluster.example.com", "metadata.example.com"}
+	if noProxy := getNoProxyByRules(installConfigRaw, rules); !reflect.DeepEqual(noProxy, expectedNoProxy) {
+		t.Errorf("expected noProxy %v, but got %v", expectedNoProxy, noProxy)
+	}
+	if _, ok := rules["invalid"]; ok {
+		t.Errorf("expected the invalid rule is ignored")
+	}
+	if expectedRule := (noProxyRule{Entries: []string{"169.254.169.254"}}); !reflect.DeepEqual(rules["aws"], expectedRule) {
+		t.Errorf("expected the built-in rule of aws is replaced by %v, but got %v", expectedRule, rules["aws"])
+	}
+	if _, ok := rules["gcp"]; !ok {
+		t.Errorf("expected the built-in rule of gcp")
+	}
+	for _, platform := range []string{"ibmcloud", "powervs"} {
+		if rule, ok := rules[platform]; !ok || !reflect.DeepEqual(rule, noProxyRule{}) {
+			t.Errorf("expected the empty built-in rule of %s, but got %v", platform, rule)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package globalproxy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_noProxyBuilder(t *testing.T) {
+	tests := []struct {
+		name            string
+		entries         []string
+		expectedNoProxy string
+		expectedInvalid []string
+	}{
+		{
+			name:            "hosts, IPs and CIDRs",
+			entries:         []string{"Registry.Example.com,.example.com", "10.0.0.1, 10.0.0.0/16", "fd00:0::1", "10.0.0.0/8"},
+			expectedNoProxy: ".example.com,10.0.0.0/8,fd00::1,registry.example.com",
+		},
+		{
+			name:            "host with port",
+			entries:         []string{"registry.example.com:5000", "10.0.0.1:8080"},
+			expectedNoProxy: "10.0.0.1:8080,registry.example.com:5000",
+		},
+		{
+			name:            "IPv6 with port",
+			entries:         []string{"[fd00::1]:5000", "fd00::1"},
+			expectedNoProxy: "[fd00::1]:5000,fd00::1",
+		},
+		{
+			name:            "invalid entries",
+			entries:         []string{"bad_host", "bad_host:5000", "registry.example.com:70000", "[fd00::1]:http", "10.300.0.0/16"},
+			expectedInvalid: []string{"bad_host", "bad_host:5000", "registry.example.com:70000", "[fd00::1]:http", "10.300.0.0/16"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := newNoProxyBuilder()
+			builder.insert(tt.entries...)
+			noProxy, invalid := builder.build()
+			if noProxy != tt.expectedNoProxy {
+				t.Errorf("expected noProxy %q, but got %q", tt.expectedNoProxy, noProxy)
+			}
+			if !reflect.DeepEqual(invalid, tt.expectedInvalid) {
+				t.Errorf("expected invalid entries %v, but got %v", tt.expectedInvalid, invalid)
+			}
+		})
+	}
+}
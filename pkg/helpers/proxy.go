@@ -0,0 +1,67 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import (
+	"net/url"
+	"strings"
+)
+
+// redactedPassword is the placeholder of the password in a redacted URL, it is the same as url.URL.Redacted.
+const redactedPassword = "xxxxx"
+
+// SplitURLCredentials returns the URL without the userinfo, and the userinfo of the URL.
+// nil userinfo is returned if there is no userinfo in the URL.
+func SplitURLCredentials(rawURL string) (string, *url.Userinfo) {
+	if u, err := url.Parse(rawURL); err == nil && u.User != nil {
+		user := u.User
+		u.User = nil
+		return u.String(), user
+	}
+
+	// the URL without scheme, like username:password@proxy.example.com:3128, cannot be parsed correctly.
+	at := strings.LastIndex(rawURL, "@")
+	if at < 0 {
+		return rawURL, nil
+	}
+	scheme, userinfo := "", rawURL[:at]
+	if i := strings.Index(userinfo, "://"); i >= 0 {
+		scheme, userinfo = userinfo[:i+3], userinfo[i+3:]
+	}
+	username, password, hasPassword := strings.Cut(userinfo, ":")
+	if hasPassword {
+		return scheme + rawURL[at+1:], url.UserPassword(username, password)
+	}
+	return scheme + rawURL[at+1:], url.User(username)
+}
+
+// SetURLCredentials returns the URL with the userinfo built from the username and password.
+// The existing userinfo of the URL is replaced.
+func SetURLCredentials(rawURL, username, password string) string {
+	u, _ := SplitURLCredentials(rawURL)
+	if username == "" && password == "" {
+		return u
+	}
+
+	userinfo := url.User(username).String()
+	if password != "" {
+		userinfo = url.UserPassword(username, password).String()
+	}
+	if i := strings.Index(u, "://"); i >= 0 {
+		return u[:i+3] + userinfo + "@" + u[i+3:]
+	}
+	return userinfo + "@" + u
+}
+
+// RedactURL returns the URL with the password replaced by "xxxxx", so it can be shown in the status, events,
+// logs and conditions.
+func RedactURL(rawURL string) string {
+	u, user := SplitURLCredentials(rawURL)
+	if user == nil {
+		return rawURL
+	}
+	if _, hasPassword := user.Password(); !hasPassword {
+		return SetURLCredentials(u, user.Username(), "")
+	}
+	return SetURLCredentials(u, user.Username(), redactedPassword)
+}
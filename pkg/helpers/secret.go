@@ -0,0 +1,35 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// cachedSecretDataKeys are the data keys of the Secrets which are read from the cache by the controllers, they are
+// the keys of the image pull secrets and the proxy credentials secrets.
+var cachedSecretDataKeys = []string{
+	corev1.DockerConfigJsonKey,
+	corev1.BasicAuthUsernameKey,
+	corev1.BasicAuthPasswordKey,
+}
+
+// TransformSecret drops the managed fields and the data of the Secret which are not read by the controllers before
+// it is stored in the cache, so the other Secrets on the hub, like the kubeconfigs and the install configs of the
+// clusters, are not kept in the memory.
+func TransformSecret(obj interface{}) (interface{}, error) {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return obj, nil
+	}
+
+	secret.ManagedFields = nil
+	data := map[string][]byte{}
+	for _, key := range cachedSecretDataKeys {
+		if value, ok := secret.Data[key]; ok {
+			data[key] = value
+		}
+	}
+	secret.Data = data
+	return secret, nil
+}
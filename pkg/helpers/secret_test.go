@@ -0,0 +1,70 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package helpers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTransformSecret(t *testing.T) {
+	tests := []struct {
+		name         string
+		obj          interface{}
+		expectedData map[string][]byte
+	}{
+		{
+			name: "image pull secret",
+			obj: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:          "pull-secret",
+					ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+				},
+				Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte("{}")},
+			},
+			expectedData: map[string][]byte{corev1.DockerConfigJsonKey: []byte("{}")},
+		},
+		{
+			name: "proxy credentials secret",
+			obj: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "proxy-credentials"},
+				Data: map[string][]byte{
+					corev1.BasicAuthUsernameKey: []byte("user"),
+					corev1.BasicAuthPasswordKey: []byte("pass"),
+					"other":                     []byte("other"),
+				},
+			},
+			expectedData: map[string][]byte{
+				corev1.BasicAuthUsernameKey: []byte("user"),
+				corev1.BasicAuthPasswordKey: []byte("pass"),
+			},
+		},
+		{
+			name: "kubeconfig secret",
+			obj: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster1-admin-kubeconfig"},
+				Data:       map[string][]byte{"kubeconfig": []byte("kubeconfig")},
+			},
+			expectedData: map[string][]byte{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, err := TransformSecret(tt.obj)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			secret := obj.(*corev1.Secret)
+			if len(secret.ManagedFields) != 0 {
+				t.Errorf("expected no managed fields, but got %v", secret.ManagedFields)
+			}
+			if !reflect.DeepEqual(secret.Data, tt.expectedData) {
+				t.Errorf("expected data %v, but got %v", tt.expectedData, secret.Data)
+			}
+		})
+	}
+}
@@ -52,6 +52,58 @@ platform:
   baremetal:
     libvirtURI: qemu+ssh://root@192.168.124.1/system
 `)
+var InstallConfigTrustBundleYaml = []byte(`
+apiVersion: v1
+baseDomain: aws-cluster
+metadata:
+  name: 'cluster'
+baseDomain: test.redhat.com
+networking:
+  networkType: OpenShiftSDN
+  clusterNetwork:
+  - cidr: 10.128.0.0/14
+    hostPrefix: 23
+  machineNetwork:
+  - cidr: 192.168.124.0/24
+  serviceNetwork:
+  - 172.30.0.0/16
+proxy:
+  httpsProxy: https://proxy.example.com:123/
+  httpProxy: http://proxy.example.com:123/
+  noProxy: 123.example.com
+additionalTrustBundle: |
+  -----BEGIN CERTIFICATE-----
+  MIIBfake
+  -----END CERTIFICATE-----
+platform:
+  baremetal:
+    libvirtURI: qemu+ssh://root@192.168.124.1/system
+`)
+var InstallConfigDualStackYaml = []byte(`
+apiVersion: v1
+metadata:
+  name: 'cluster'
+baseDomain: test.redhat.com
+networking:
+  networkType: OVNKubernetes
+  clusterNetwork:
+  - cidr: 10.128.0.0/14
+    hostPrefix: 23
+  - cidr: fd01::/48
+    hostPrefix: 64
+  machineNetwork:
+  - cidr: 10.0.0.0/16
+  - cidr: FD00:0:0::/48
+  serviceNetwork:
+  - 172.30.0.0/16
+  - fd02::/112
+proxy:
+  httpsProxy: http://proxy.example.com:3128
+  httpProxy: http://proxy.example.com:3128
+  noProxy: registry.example.com, 10.0.1.5, fd00::10, 10.0.0.0/24, 10.300.0.0/16, bad_host, REGISTRY.example.com
+platform:
+  none: {}
+`)
 var InstallConfigNoProxyYaml = []byte(`
 apiVersion: v1
 baseDomain: aws-cluster